@@ -0,0 +1,456 @@
+package tnpldraft
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned when an admin change isn't allowed in the draft's current state.
+var (
+	ErrDraftStarted = errors.New("the draft has already started")
+	ErrDraftRunning = errors.New("teams are connected to the draft")
+)
+
+// The configuration of a draft that admins create and update. Name and
+// Settings may be changed at any time; the rest only before the draft
+// starts. New drafts without Settings get defaultSettings; updates
+// without Settings keep the draft's current settings.
+type DraftConfig struct {
+	Name        string         `json:"name"`
+	SalaryCap   int            `json:"salary_cap"`
+	MinSalary   int            `json:"min_salary"`
+	MaxSalary   int            `json:"max_salary"`
+	RequiredPos map[string]int `json:"positions"`
+	Settings    *DraftSettings `json:"settings,omitempty"`
+}
+
+// A team as created and updated by admins. Order is the team's place in
+// the nomination order; 0 puts new teams last and leaves existing teams
+// where they are. Name may be changed at any time, Order only before the
+// draft starts.
+type TeamConfig struct {
+	Name  string `json:"name"`
+	Order int    `json:"order"`
+}
+
+// A draft as shown to admins.
+type DraftAdminView struct {
+	DraftConfig
+	Id      int64            `json:"id"`
+	Started bool             `json:"started"`
+	Leaders []string         `json:"leaders"`
+	Teams   []*TeamAdminView `json:"teams"` // in draft order
+}
+
+type TeamAdminView struct {
+	Id     TeamId   `json:"id"`
+	Name   string   `json:"name"`
+	Owners []string `json:"owners"`
+}
+
+// Calls change with draftId's controller and whether it's running. A
+// running controller is changed inside its event loop; otherwise change
+// gets the draft as loaded from the store, without being prepared, and
+// the draft can't start until change returns.
+func (supervisor *DraftSupervisor) configure(draftId int64, change func(c *DraftController, running bool) error) error {
+	supervisor.lockConfigured(draftId)
+	ctrl, running := supervisor.drafts[draftId]
+	if !running {
+		done := make(chan struct{})
+		supervisor.configuring[draftId] = done
+		supervisor.Unlock()
+		defer func() {
+			supervisor.Lock()
+			delete(supervisor.configuring, draftId)
+			supervisor.Unlock()
+			close(done)
+		}()
+		c, err := supervisor.store.LoadDraft(draftId)
+		if err != nil {
+			return err
+		}
+		events, err := supervisor.store.LoadEvents(draftId)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if event.Type == teamsReadyEvent {
+				c.started = true
+			}
+		}
+		return change(c, false)
+	}
+	supervisor.Unlock()
+	var err error
+	request := &inspectRequest{
+		fn: func(c *DraftController) {
+			err = change(c, true)
+		},
+		done: make(chan struct{}),
+	}
+	select {
+	case ctrl.inspect <- request:
+		<-request.done
+		return err
+	case <-ctrl.exited:
+		// Try again once the supervisor has removed it.
+		return supervisor.configure(draftId, change)
+	}
+}
+
+// Locks the supervisor once nobody is configuring draftId.
+func (supervisor *DraftSupervisor) lockConfigured(draftId int64) {
+	supervisor.Lock()
+	for {
+		done, ok := supervisor.configuring[draftId]
+		if !ok {
+			return
+		}
+		supervisor.Unlock()
+		<-done
+		supervisor.Lock()
+	}
+}
+
+// Returns why the teams and roster requirements of c can no longer
+// change, or nil if they can.
+func structureFrozen(c *DraftController, running bool) error {
+	if c.started {
+		return ErrDraftStarted
+	}
+	if running {
+		// The running controller was built from the old configuration.
+		return ErrDraftRunning
+	}
+	return nil
+}
+
+func validateDraftConfig(config *DraftConfig) error {
+	if config.Name == "" {
+		return errors.New("drafts need a name")
+	}
+	if config.SalaryCap <= 0 || config.MinSalary < 0 || config.MaxSalary < 0 {
+		return errors.New("salaries can't be negative and the salary cap must be positive")
+	}
+	if config.MaxSalary > 0 && config.MaxSalary < config.MinSalary {
+		return errors.New("the maximum salary can't be less than the minimum")
+	}
+	for pos, count := range config.RequiredPos {
+		if count < 0 {
+			return fmt.Errorf("position %v needs a count of at least 0", pos)
+		}
+	}
+	if config.Settings != nil {
+		return validateSettings(config.Settings)
+	}
+	return nil
+}
+
+// Returns the settings of drafts created without any, matching the
+// defaults of the draft table.
+func defaultSettings() *DraftSettings {
+	return &DraftSettings{
+		AuctionSeconds:            30,
+		BidExtensionSeconds:       20,
+		ExtensionThresholdSeconds: 20,
+		NominationTimeout:         SkipNomination,
+	}
+}
+
+func validateSettings(settings *DraftSettings) error {
+	if settings.AuctionSeconds <= 0 || settings.BidExtensionSeconds <= 0 || settings.ExtensionThresholdSeconds < 0 {
+		return errors.New("auction and extension durations must be positive")
+	}
+	if settings.NominationSeconds < 0 {
+		return errors.New("the nomination clock can't be negative")
+	}
+	switch settings.NominationTimeout {
+	case "", AutoNominate, SkipNomination:
+		// Anything but AutoNominate skips the team.
+	default:
+		return fmt.Errorf("nomination timeout must be %q or %q", AutoNominate, SkipNomination)
+	}
+	for i, tier := range settings.BidIncrements {
+		if tier.Increment <= 0 {
+			return errors.New("bid increments must be positive")
+		}
+		if tier.From < 0 || (i > 0 && tier.From <= settings.BidIncrements[i-1].From) {
+			return errors.New("bid increments must be ordered by the bid they start from")
+		}
+	}
+	return nil
+}
+
+// Whether config changes anything about c that can't be changed once the
+// draft starts.
+func changesStructure(c *DraftController, config *DraftConfig) bool {
+	// A running controller has already replaced a stored 0 with the
+	// default.
+	if effectiveMinSalary(config.MinSalary) != effectiveMinSalary(c.MinSalary) {
+		return true
+	}
+	if config.SalaryCap != c.SalaryCap || config.MaxSalary != c.MaxSalary {
+		return true
+	}
+	if len(config.RequiredPos) != len(c.RequiredPos) {
+		return true
+	}
+	for pos, count := range config.RequiredPos {
+		if current, ok := c.RequiredPos[pos]; !ok || current != count {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies settings changed by an admin to a running draft.
+func (c *DraftController) applySettings(settings DraftSettings) {
+	c.Settings = settings
+	c.broadcast(SocketMessageFrom(SettingsChanged{
+		Settings: c.Settings,
+	}))
+	c.disconnectSpectators()
+	if !c.Settings.RequireApproval && c.state == PICK_PENDING_APPROVAL {
+		c.StartBidding(c.auction.player, c.auction.bid)
+	}
+}
+
+// CreateDraft stores a new draft without any teams and returns its id.
+func (supervisor *DraftSupervisor) CreateDraft(config *DraftConfig) (int64, error) {
+	if err := validateDraftConfig(config); err != nil {
+		return 0, err
+	}
+	if config.Settings == nil {
+		withDefaults := *config
+		withDefaults.Settings = defaultSettings()
+		config = &withDefaults
+	}
+	return supervisor.store.CreateDraft(config)
+}
+
+// DraftConfig returns draftId as admins see it.
+func (supervisor *DraftSupervisor) DraftConfig(draftId int64) (*DraftAdminView, error) {
+	var view *DraftAdminView
+	err := supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		settings := c.Settings
+		settings.BidIncrements = append([]BidIncrement{}, c.Settings.BidIncrements...)
+		view = &DraftAdminView{
+			DraftConfig: DraftConfig{
+				Name:        c.Name,
+				SalaryCap:   c.SalaryCap,
+				MinSalary:   c.MinSalary,
+				MaxSalary:   c.MaxSalary,
+				RequiredPos: c.RequiredPos,
+				Settings:    &settings,
+			},
+			Id:      draftId,
+			Started: c.started,
+			Leaders: append([]string{}, c.leaders...),
+			Teams:   make([]*TeamAdminView, len(c.Teams)),
+		}
+		for i, team := range c.Teams {
+			view.Teams[i] = &TeamAdminView{
+				Id:     team.Id,
+				Name:   team.Name,
+				Owners: append([]string{}, team.owners...),
+			}
+		}
+		return nil
+	})
+	return view, err
+}
+
+// UpdateDraft replaces the configuration of draftId. Only Name and
+// Settings may change once the draft has started or while it's running.
+func (supervisor *DraftSupervisor) UpdateDraft(draftId int64, config *DraftConfig) error {
+	if err := validateDraftConfig(config); err != nil {
+		return err
+	}
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		if changesStructure(c, config) {
+			if err := structureFrozen(c, running); err != nil {
+				return err
+			}
+		}
+		if config.Settings == nil {
+			current := c.Settings
+			update := *config
+			update.Settings = &current
+			config = &update
+		}
+		if err := supervisor.store.UpdateDraft(draftId, config); err != nil {
+			return err
+		}
+		if running {
+			c.Name = config.Name
+			c.applySettings(*config.Settings)
+		}
+		return nil
+	})
+}
+
+// DeleteDraft removes draftId and everything in it. Drafts can't be
+// deleted once they've started.
+func (supervisor *DraftSupervisor) DeleteDraft(draftId int64) error {
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		if err := structureFrozen(c, running); err != nil {
+			return err
+		}
+		return supervisor.store.DeleteDraft(draftId)
+	})
+}
+
+// AddTeam adds a team without owners to draftId and returns its id.
+func (supervisor *DraftSupervisor) AddTeam(draftId int64, config *TeamConfig) (TeamId, error) {
+	if config.Name == "" {
+		return 0, errors.New("teams need a name")
+	}
+	var teamId TeamId
+	err := supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		if err := structureFrozen(c, running); err != nil {
+			return err
+		}
+		var err error
+		teamId, err = supervisor.store.CreateTeam(draftId, config)
+		return err
+	})
+	return teamId, err
+}
+
+// Returns the team in c with id teamId, or an error if there isn't one.
+func findTeam(c *DraftController, teamId TeamId) (*Team, error) {
+	if team := c.teamById(teamId); team != nil {
+		return team, nil
+	}
+	return nil, fmt.Errorf("no team %v in draft %v", teamId, c.id)
+}
+
+// UpdateTeam renames teamId and, before the draft starts, moves it to
+// config.Order.
+func (supervisor *DraftSupervisor) UpdateTeam(draftId int64, teamId TeamId, config *TeamConfig) error {
+	if config.Name == "" {
+		return errors.New("teams need a name")
+	}
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		team, err := findTeam(c, teamId)
+		if err != nil {
+			return err
+		}
+		if config.Order != 0 {
+			if err := structureFrozen(c, running); err != nil {
+				return err
+			}
+		}
+		if err := supervisor.store.UpdateTeam(teamId, config); err != nil {
+			return err
+		}
+		team.Name = config.Name
+		return nil
+	})
+}
+
+// DeleteTeam removes teamId from a draft that hasn't started.
+func (supervisor *DraftSupervisor) DeleteTeam(draftId int64, teamId TeamId) error {
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		if _, err := findTeam(c, teamId); err != nil {
+			return err
+		}
+		if err := structureFrozen(c, running); err != nil {
+			return err
+		}
+		return supervisor.store.DeleteTeam(teamId)
+	})
+}
+
+// AddOwner lets email run teamId. Owners may be added at any time, but
+// nobody may own more than one team in a draft. Connections email already
+// has open are closed.
+func (supervisor *DraftSupervisor) AddOwner(draftId int64, teamId TeamId, email string) error {
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		team, err := findTeam(c, teamId)
+		if err != nil {
+			return err
+		}
+		for _, other := range c.Teams {
+			if contains(other.owners, email) {
+				return fmt.Errorf("%v already owns %v", email, other.Name)
+			}
+		}
+		if err := supervisor.store.AddOwner(teamId, email); err != nil {
+			return err
+		}
+		if running {
+			// Connections are kept by team; email reconnects as an owner.
+			c.closeConnections(email)
+			c.owners[email] = team
+		}
+		team.owners = append(team.owners, email)
+		return nil
+	})
+}
+
+// RemoveOwner stops email from running teamId. Connections they already
+// have open are closed.
+func (supervisor *DraftSupervisor) RemoveOwner(draftId int64, teamId TeamId, email string) error {
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		team, err := findTeam(c, teamId)
+		if err != nil {
+			return err
+		}
+		if !contains(team.owners, email) {
+			return fmt.Errorf("%v doesn't own %v", email, team.Name)
+		}
+		if err := supervisor.store.RemoveOwner(teamId, email); err != nil {
+			return err
+		}
+		if running {
+			c.closeConnections(email)
+			delete(c.owners, email)
+		}
+		team.owners = remove(team.owners, email)
+		return nil
+	})
+}
+
+// AddLeader makes email a leader of draftId.
+func (supervisor *DraftSupervisor) AddLeader(draftId int64, email string) error {
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		if c.isLeader(email) {
+			return nil
+		}
+		if err := supervisor.store.AddLeader(draftId, email); err != nil {
+			return err
+		}
+		c.leaders = append(c.leaders, email)
+		return nil
+	})
+}
+
+// RemoveLeader stops email leading draftId. Their connections are closed
+// unless they own a team or may spectate.
+func (supervisor *DraftSupervisor) RemoveLeader(draftId int64, email string) error {
+	return supervisor.configure(draftId, func(c *DraftController, running bool) error {
+		if !c.isLeader(email) {
+			return fmt.Errorf("%v isn't a leader of draft %v", email, draftId)
+		}
+		if err := supervisor.store.RemoveLeader(draftId, email); err != nil {
+			return err
+		}
+		c.leaders = remove(c.leaders, email)
+		if running {
+			c.disconnectSpectators()
+		}
+		return nil
+	})
+}
+
+// Returns list without s.
+func remove(list []string, s string) []string {
+	kept := []string{}
+	for _, l := range list {
+		if l != s {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,125 @@
+package tnpldraft
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// A player on a team's final roster, as exported for league spreadsheets.
+// Keepers have no pick number or offering team. Players assigned by a
+// leader have no offering team. Salary is in cents in both JSON and CSV.
+type ExportRow struct {
+	PickNumber   int    `json:"pick_number,omitempty"`
+	PlayerId     int64  `json:"player_id"`
+	Player       string `json:"player"`
+	Mlbteam      string `json:"mlbteam"`
+	Salary       int    `json:"salary_cents"`
+	OfferingTeam string `json:"offering_team,omitempty"`
+	WinningTeam  string `json:"winning_team"`
+	Slot         string `json:"slot"`
+	Keeper       bool   `json:"keeper"`
+}
+
+// Column headings of exported CSV files, in the order WriteCSV writes
+// them.
+var exportColumns = []string{"pick_number", "player_id", "player", "mlbteam", "salary_cents", "offering_team", "winning_team", "slot", "keeper"}
+
+// Returns a row for every player on every team: keepers first, by team in
+// draft order, then picks in draft order.
+func (c *DraftController) exportRows() []*ExportRow {
+	rows := []*ExportRow{}
+	row := func(player *OwnedPlayer, team *Team) *ExportRow {
+		return &ExportRow{
+			PlayerId:    player.Id,
+			Player:      player.Firstname + " " + player.Lastname,
+			Mlbteam:     player.Mlbteam,
+			Salary:      player.Salary,
+			WinningTeam: team.Name,
+			Slot:        player.Slot,
+			Keeper:      player.Keeper,
+		}
+	}
+	for _, team := range c.Teams {
+		for _, player := range team.Players {
+			if player.Keeper {
+				rows = append(rows, row(player, team))
+			}
+		}
+	}
+	for _, auction := range c.CompletedAuctions {
+		team := c.teamById(auction.WinningTeam)
+		player := auction.Player
+		// The roster's copy of the player has their current slot.
+		for _, owned := range team.Players {
+			if owned.Id == player.Id {
+				player = owned
+			}
+		}
+		exported := row(player, team)
+		exported.PickNumber = auction.PickNumber
+		if offeringTeam := c.teamById(auction.OfferingTeam); offeringTeam != nil {
+			exported.OfferingTeam = offeringTeam.Name
+		}
+		rows = append(rows, exported)
+	}
+	return rows
+}
+
+// Export returns the final rosters of draftId as seen by email. Returns
+// ErrNotAllowed if email may not view the draft.
+func (supervisor *DraftSupervisor) Export(draftId int64, email string) ([]*ExportRow, error) {
+	var (
+		rows []*ExportRow
+		err  error
+	)
+	if inspectErr := supervisor.inspect(draftId, func(c *DraftController) {
+		if !c.canView(email) {
+			err = ErrNotAllowed
+			return
+		}
+		rows = c.exportRows()
+	}); inspectErr != nil {
+		return nil, inspectErr
+	}
+	return rows, err
+}
+
+// ExportDraft returns the final rosters of draftId as stored in store.
+func ExportDraft(store DraftStore, draftId int64) ([]*ExportRow, error) {
+	controller, err := NewController(draftId, store)
+	if err != nil {
+		return nil, err
+	}
+	return controller.exportRows(), nil
+}
+
+// WriteCSV writes rows to w as CSV, with a heading row first.
+func WriteCSV(w io.Writer, rows []*ExportRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		pickNumber := ""
+		if row.PickNumber != 0 {
+			pickNumber = strconv.Itoa(row.PickNumber)
+		}
+		record := []string{
+			pickNumber,
+			strconv.FormatInt(row.PlayerId, 10),
+			row.Player,
+			row.Mlbteam,
+			strconv.Itoa(row.Salary),
+			row.OfferingTeam,
+			row.WinningTeam,
+			row.Slot,
+			strconv.FormatBool(row.Keeper),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
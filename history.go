@@ -0,0 +1,82 @@
+package tnpldraft
+
+// Number of recently sent messages kept for reconnecting clients. Must be
+// less than the size of a connection's outbound buffer.
+const historySize = 256
+
+// Resume identifies the last message a reconnecting client received.
+type Resume struct {
+	Epoch int64
+	Seq   int64
+}
+
+// A message recently sent to a team, every team (team is nil) or the draft
+// leaders.
+type sentMessage struct {
+	msg     *SocketMessage
+	team    *Team
+	leaders bool
+}
+
+func (c *DraftController) isFor(sent *sentMessage, conn Connection, team *Team) bool {
+	switch {
+	case sent.leaders:
+		return c.isLeader(conn.User.Email)
+	case sent.team != nil:
+		return sent.team == team
+	}
+	return true
+}
+
+// Assigns msg the next sequence number and remembers it for reconnecting
+// clients. Returns the message to send, which is a copy if msg was
+// already sent to someone else.
+func (c *DraftController) stamp(msg *SocketMessage, team *Team, leaders bool) *SocketMessage {
+	// Nobody receives messages sent while replaying the journal.
+	if c.replaying {
+		return msg
+	}
+	if msg.Seq != 0 {
+		stamped := *msg
+		msg = &stamped
+	}
+	c.seq++
+	msg.Seq = c.seq
+	c.history = append(c.history, &sentMessage{
+		msg:     msg,
+		team:    team,
+		leaders: leaders,
+	})
+	if len(c.history) > historySize {
+		c.history = c.history[1:]
+	}
+	return msg
+}
+
+func (c *DraftController) sendToTeam(team *Team, msg *SocketMessage) {
+	team.SendMessage(c.stamp(msg, team, false))
+}
+
+// Sends conn the messages it missed since resume. Returns false if it
+// can't, because resume is from another controller or too long ago, in
+// which case conn needs a snapshot of the draft instead.
+func (c *DraftController) resend(conn Connection, team *Team, sendCh chan<- *SocketMessage, resume *Resume) bool {
+	if resume == nil || resume.Epoch != c.epoch || resume.Seq > c.seq {
+		return false
+	}
+	// The oldest remembered message must immediately follow the last one
+	// the client saw.
+	oldest := c.seq + 1
+	if len(c.history) > 0 {
+		oldest = c.history[0].msg.Seq
+	}
+	if resume.Seq < oldest-1 {
+		return false
+	}
+	for _, sent := range c.history {
+		if sent.msg.Seq > resume.Seq && c.isFor(sent, conn, team) {
+			sendCh <- sent.msg
+		}
+	}
+	return true
+}
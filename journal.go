@@ -0,0 +1,260 @@
+package tnpldraft
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ggriffiniii/googleauth"
+	"log"
+	"time"
+)
+
+// Types of journal events that aren't socket messages. Every other event
+// Type is the Type of the SocketMessage a team sent.
+const (
+	registerEvent          = "Register"
+	teamsReadyEvent        = "TeamsReady"
+	auctionExpiredEvent    = "AuctionExpired"
+	auctionUndoneEvent     = "AuctionUndone"
+	playerAssignedEvent    = "PlayerAssigned"
+	nominationExpiredEvent = "NominationExpired"
+	autoNominateEvent      = "AutoNominate"
+	draftCompleteEvent     = "DraftComplete"
+)
+
+// JournalEvent is an entry in a draft's append-only journal. The
+// controller appends an event for every registration, every message
+// received from a team and every auction expiration. Replaying the
+// events in order rebuilds the controller's state.
+type JournalEvent struct {
+	Seq   int64           `json:"seq"`
+	Time  time.Time       `json:"time"`
+	Email string          `json:"email"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Data of auctionUndoneEvent: the team that offered the undone auction,
+// which nominates next.
+type auctionUndoneData struct {
+	OfferingTeam TeamId `json:"offering_team"`
+}
+
+// Returns true if the event's effects are already reflected by the draft
+// as loaded by DraftStore, so recovery only needs to replay the events
+// that follow it.
+func (event *JournalEvent) isCheckpoint() bool {
+	switch event.Type {
+	case auctionExpiredEvent, auctionUndoneEvent, playerAssignedEvent:
+		return true
+	}
+	return false
+}
+
+func (c *DraftController) now() time.Time {
+	if c.replaying {
+		return c.replayTime
+	}
+	return time.Now()
+}
+
+func (c *DraftController) journal(email, eventType string, data json.RawMessage) {
+	if c.replaying {
+		return
+	}
+	event := JournalEvent{
+		Seq:   c.journalSeq + 1,
+		Time:  c.now(),
+		Email: email,
+		Type:  eventType,
+		Data:  data,
+	}
+	if err := c.store.AppendEvent(c.id, &event); err != nil {
+		log.Printf("Unable to journal %v event for draft %v: %v", eventType, c.id, err)
+		return
+	}
+	c.journalSeq = event.Seq
+}
+
+// Applies events to the controller without journaling or persisting
+// anything. Teams must not have any connections while replaying.
+func (c *DraftController) replay(events []*JournalEvent) {
+	c.replaying = true
+	defer func() { c.replaying = false }()
+	for _, event := range events {
+		c.replayTime = event.Time
+		switch event.Type {
+		case registerEvent, draftCompleteEvent, auctionUndoneEvent, playerAssignedEvent:
+			// Nothing to apply. Draft completion follows from the
+			// auction that preceeded it, and undos and assignments
+			// are applied when the leader's message is replayed.
+		case teamsReadyEvent:
+			if c.state == WAITING_FOR_TEAMS {
+				c.startAuction(c.auction)
+			}
+		case auctionExpiredEvent:
+			if c.state != AUCTION_IN_PROGRESS {
+				log.Printf("Journal event %v: auction expired when no auction was in progress", event.Seq)
+				continue
+			}
+			c.finishAuction()
+		case nominationExpiredEvent, autoNominateEvent:
+			if c.state != WAITING_FOR_PICK {
+				log.Printf("Journal event %v: nomination when not waiting for a pick", event.Seq)
+				continue
+			}
+			c.replayNomination(event)
+		default:
+			c.handleMessage(&TeamMessage{
+				Connection: Connection{
+					User: &googleauth.Profile{Email: event.Email},
+				},
+				SocketMessage: SocketMessage{
+					Type: event.Type,
+					Data: event.Data,
+				},
+			})
+		}
+	}
+}
+
+// Restores any state that was in progress when the controller last
+// exited, such as the auction on the block and its bids.
+func (c *DraftController) recover(events []*JournalEvent) {
+	start := 0
+	for i, event := range events {
+		if event.isCheckpoint() {
+			start = i + 1
+		}
+	}
+	if start > 0 && events[start-1].Type == auctionUndoneEvent && c.state != DRAFT_COMPLETE {
+		// The store doesn't know who offered the undone auction, so
+		// resumeAuction may have picked the wrong team.
+		var undone auctionUndoneData
+		if err := json.Unmarshal(events[start-1].Data, &undone); err == nil {
+			if auction := c.auctionAfterUndo(undone.OfferingTeam); auction != nil {
+				c.auction = auction
+			}
+		}
+	}
+	if start == len(events) || c.state == DRAFT_COMPLETE {
+		return
+	}
+	log.Printf("Recovering draft %v from %v journal events", c.id, len(events)-start)
+	if start > 0 {
+		// The draft started before the checkpoint, so the events after
+		// it were received while waiting for a pick.
+		c.state = WAITING_FOR_PICK
+	}
+	c.replay(events[start:])
+	switch c.state {
+	case WAITING_FOR_PICK:
+		// Nothing was in progress. Wait for teams to reconnect as usual.
+		c.state = WAITING_FOR_TEAMS
+	case AUCTION_IN_PROGRESS:
+		// Give teams time to reconnect before the recovered auction
+		// expires.
+		if resume := time.Now().Add(c.Settings.auctionDuration()); c.auction.endTime.Before(resume) {
+			c.auction.endTime = resume
+		}
+	}
+}
+
+// Journaled messages only the sending team and leaders may see: proxy
+// bids carry the team's hidden maximum and queues are private to the team.
+var privateEvents = map[string]bool{
+	"ProxyBid":           true,
+	"SetNominationQueue": true,
+}
+
+// Journal returns draftId's journal as email may see it. Leaders see every
+// event; anyone else who may view the draft sees all but private events.
+// Returns ErrNotAllowed if email may not view the draft.
+func (supervisor *DraftSupervisor) Journal(draftId int64, email string) ([]*JournalEvent, error) {
+	leader, err := supervisor.viewer(draftId, email)
+	if err != nil {
+		return nil, err
+	}
+	events, err := supervisor.store.LoadEvents(draftId)
+	if err != nil {
+		return nil, err
+	}
+	if leader {
+		return events, nil
+	}
+	public := []*JournalEvent{}
+	for _, event := range events {
+		if !privateEvents[event.Type] {
+			public = append(public, event)
+		}
+	}
+	return public, nil
+}
+
+// Replay returns a snapshot of draftId as it was immediately after the
+// journal event numbered until, encoded as JSON. Only leaders may step
+// through a draft; anyone else gets ErrNotAllowed.
+func (supervisor *DraftSupervisor) Replay(draftId int64, email string, until int64) (json.RawMessage, error) {
+	leader, err := supervisor.viewer(draftId, email)
+	if err != nil {
+		return nil, err
+	}
+	if !leader {
+		return nil, ErrNotAllowed
+	}
+	controller, err := replayDraft(supervisor.store, draftId, until)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(controller.snapshot())
+}
+
+// Returns whether email leads draftId, or ErrNotAllowed if they may not
+// view it at all.
+func (supervisor *DraftSupervisor) viewer(draftId int64, email string) (bool, error) {
+	var leader, allowed bool
+	if err := supervisor.inspect(draftId, func(c *DraftController) {
+		leader = c.isLeader(email)
+		allowed = c.canView(email)
+	}); err != nil {
+		return false, err
+	}
+	if !allowed {
+		return false, ErrNotAllowed
+	}
+	return leader, nil
+}
+
+// Rebuilds draftId from its journal, applying every event with a sequence
+// number up to and including until. The returned controller reflects the
+// draft as it was immediately after that event and is only suitable for
+// inspection; it is never run.
+func replayDraft(store DraftStore, draftId int64, until int64) (*DraftController, error) {
+	controller, err := store.LoadDraft(draftId)
+	if err != nil {
+		return nil, err
+	}
+	// Undo the picks recorded by the store; the journal replays them.
+	for _, auction := range controller.CompletedAuctions {
+		team := controller.teamById(auction.WinningTeam)
+		if team == nil {
+			return nil, fmt.Errorf("pick %v references unknown team %v", auction.PickNumber, auction.WinningTeam)
+		}
+		team.removePlayer(auction.Player)
+	}
+	controller.CompletedAuctions = []*AuctionComplete{}
+	if err := controller.prepare(draftId, store); err != nil {
+		return nil, err
+	}
+	events, err := store.LoadEvents(draftId)
+	if err != nil {
+		return nil, err
+	}
+	for i, event := range events {
+		if event.Seq > until {
+			events = events[:i]
+			break
+		}
+	}
+	controller.replay(events)
+	return controller, nil
+}
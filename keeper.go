@@ -0,0 +1,125 @@
+package tnpldraft
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+func (c *DraftController) setKeeper(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var keeper SetKeeper
+	if err := json.Unmarshal(msg.SocketMessage.Data, &keeper); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	if c.started {
+		c.rejectCommand(team, msg, "Keepers are locked once the draft starts")
+		return
+	}
+	keepingTeam := c.teamById(keeper.Team)
+	if keepingTeam == nil {
+		c.rejectCommand(team, msg, "Unknown team")
+		return
+	}
+	player := c.lookupPlayer(keeper.PlayerId)
+	if player == nil {
+		c.rejectCommand(team, msg, "Unknown player")
+		return
+	}
+	if owner := c.ownerOf(player.Id); owner != nil {
+		c.rejectCommand(team, msg, fmt.Sprintf("That player is already on %v", owner.Name))
+		return
+	}
+	if !c.teamHasRoomFor(keepingTeam, player) {
+		c.rejectCommand(team, msg, fmt.Sprintf("%v has no room for that player", keepingTeam.Name))
+		return
+	}
+	// maxTeamCanBid leaves enough of the cap to fill the rest of the
+	// roster at the minimum salary.
+	if keeper.Salary < c.MinSalary || keeper.Salary > c.maxTeamCanBid(keepingTeam) {
+		c.rejectCommand(team, msg, fmt.Sprintf("%v can't fit that salary under the cap", keepingTeam.Name))
+		return
+	}
+	owned := &OwnedPlayer{
+		Player: player,
+		Salary: keeper.Salary,
+		Keeper: true,
+	}
+	if !c.replaying {
+		if err := c.store.SaveKeeper(keepingTeam.Id, owned); err != nil {
+			log.Printf("Unable to save keeper %v for team %v: %v", owned.Id, keepingTeam.Name, err)
+			c.rejectCommand(team, msg, "Unable to save the keeper")
+			return
+		}
+	}
+	c.addPlayer(keepingTeam, owned)
+	if c.teamIsFull(keepingTeam) {
+		// The team may have been first to nominate.
+		c.resetFirstAuction()
+	}
+	c.broadcast(SocketMessageFrom(KeeperAdded{
+		Team:   keepingTeam.Id,
+		Player: owned,
+	}))
+	c.sendToTeam(keepingTeam, SocketMessageFrom(c.GetMaxBidMessage(keepingTeam)))
+	c.removeFromQueues(owned.Id)
+}
+
+// Picks the team that nominates first after keepers change before the
+// draft starts. The draft is complete if keepers fill every roster.
+func (c *DraftController) resetFirstAuction() {
+	c.auction = c.resumeAuction()
+	if c.auction == nil {
+		c.state = DRAFT_COMPLETE
+	} else {
+		c.state = WAITING_FOR_TEAMS
+	}
+}
+
+func (c *DraftController) removeKeeper(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var remove RemoveKeeper
+	if err := json.Unmarshal(msg.SocketMessage.Data, &remove); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	if c.started {
+		c.rejectCommand(team, msg, "Keepers are locked once the draft starts")
+		return
+	}
+	keepingTeam := c.teamById(remove.Team)
+	if keepingTeam == nil {
+		c.rejectCommand(team, msg, "Unknown team")
+		return
+	}
+	var keeper *OwnedPlayer
+	for _, player := range keepingTeam.Players {
+		if player.Keeper && player.Id == remove.PlayerId {
+			keeper = player
+			break
+		}
+	}
+	if keeper == nil {
+		c.rejectCommand(team, msg, fmt.Sprintf("That player isn't kept by %v", keepingTeam.Name))
+		return
+	}
+	if !c.replaying {
+		if err := c.store.DeleteKeeper(keepingTeam.Id, keeper.Id); err != nil {
+			log.Printf("Unable to delete keeper %v for team %v: %v", keeper.Id, keepingTeam.Name, err)
+			c.rejectCommand(team, msg, "Unable to remove the keeper")
+			return
+		}
+	}
+	c.dropPlayer(keepingTeam, keeper)
+	c.resetFirstAuction()
+	c.broadcast(SocketMessageFrom(KeeperRemoved{
+		Team:     keepingTeam.Id,
+		PlayerId: keeper.Id,
+	}))
+	c.sendToTeam(keepingTeam, SocketMessageFrom(c.GetMaxBidMessage(keepingTeam)))
+}
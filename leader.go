@@ -0,0 +1,484 @@
+package tnpldraft
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Messages only leaders may send. Leaders who don't own a team may send
+// these without one.
+var leaderCommands = map[string]bool{
+	"ApprovePick":         true,
+	"RejectPick":          true,
+	"SetApprovalRequired": true,
+	"SetAuctionTiming":    true,
+	"SetSpectators":       true,
+	"AssignPlayer":        true,
+	"SetKeeper":           true,
+	"RemoveKeeper":        true,
+	"KickConnection":      true,
+	"PauseDraft":          true,
+	"ResumeDraft":         true,
+	"UndoAuction":         true,
+}
+
+// DraftSettings are the parts of a draft's configuration that leaders may
+// change while the draft is running.
+type DraftSettings struct {
+	// Picks must be approved by a leader before bidding starts.
+	RequireApproval bool `json:"require_approval"`
+
+	// How long an auction lasts when bidding starts.
+	AuctionSeconds int `json:"auction_seconds"`
+	// A bid received with less than ExtensionThresholdSeconds left in the
+	// auction extends it to end BidExtensionSeconds after the bid.
+	BidExtensionSeconds       int `json:"bid_extension_seconds"`
+	ExtensionThresholdSeconds int `json:"extension_threshold_seconds"`
+
+	// How long a team has to pick a player. Zero means forever.
+	NominationSeconds int `json:"nomination_seconds"`
+	// What happens when the nomination clock runs out; either
+	// AutoNominate or SkipNomination.
+	NominationTimeout string `json:"nomination_timeout"`
+
+	// Whether people who don't own a team may watch the draft.
+	AllowSpectators bool `json:"allow_spectators"`
+
+	// Minimum raises over the current bid, ordered by From. When empty every
+	// raise must be at least defaultBidIncrement.
+	BidIncrements []BidIncrement `json:"bid_increments"`
+}
+
+// BidIncrement is the minimum raise over any current bid of at least From.
+type BidIncrement struct {
+	From      int `json:"from"`
+	Increment int `json:"increment"`
+}
+
+const defaultBidIncrement = 50
+
+func (settings *DraftSettings) bidIncrement(bid int) int {
+	increment := defaultBidIncrement
+	for _, tier := range settings.BidIncrements {
+		if bid < tier.From {
+			break
+		}
+		increment = tier.Increment
+	}
+	return increment
+}
+
+func (settings *DraftSettings) auctionDuration() time.Duration {
+	return time.Duration(settings.AuctionSeconds) * time.Second
+}
+
+func (settings *DraftSettings) nominationDuration() time.Duration {
+	return time.Duration(settings.NominationSeconds) * time.Second
+}
+
+func (settings *DraftSettings) bidExtension() time.Duration {
+	return time.Duration(settings.BidExtensionSeconds) * time.Second
+}
+
+func (settings *DraftSettings) extensionThreshold() time.Duration {
+	return time.Duration(settings.ExtensionThresholdSeconds) * time.Second
+}
+
+func (c *DraftController) isLeader(email string) bool {
+	for _, leader := range c.leaders {
+		if leader == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends msg to every connection belonging to a leader, except those of
+// the team in exclude.
+func (c *DraftController) sendToLeaders(msg *SocketMessage, exclude *Team) {
+	msg = c.stamp(msg, nil, true)
+	for _, team := range c.Teams {
+		if team == exclude {
+			continue
+		}
+		for conn, ch := range team.connections {
+			if c.isLeader(conn.User.Email) {
+				sendToChannel(ch, msg)
+			}
+		}
+	}
+	for conn, ch := range c.observers {
+		if c.isLeader(conn.User.Email) {
+			sendToChannel(ch, msg)
+		}
+	}
+}
+
+// Returns true if msg was sent by a leader. Otherwise tells the sender the
+// command was rejected.
+func (c *DraftController) checkLeader(team *Team, msg *TeamMessage) bool {
+	if c.isLeader(msg.User.Email) {
+		return true
+	}
+	c.rejectCommand(team, msg, "Only draft leaders may do that")
+	return false
+}
+
+func (c *DraftController) rejectCommand(team *Team, msg *TeamMessage, reason string) {
+	c.reply(team, msg, SocketMessageFrom(CommandRejected{
+		Command: msg.SocketMessage.Type,
+		Reason:  reason,
+	}))
+}
+
+func (c *DraftController) saveSettings() {
+	if c.replaying {
+		return
+	}
+	if err := c.store.SaveSettings(c.id, &c.Settings); err != nil {
+		log.Printf("Unable to save settings for draft %v: %v", c.id, err)
+	}
+}
+
+func (c *DraftController) requestApproval(player *Player, bid int) {
+	c.auction.player = player
+	c.auction.bid = bid
+	log.Println("PICK_PENDING_APPROVAL")
+	c.state = PICK_PENDING_APPROVAL
+	msg := SocketMessageFrom(c.GetPickPendingApprovalMessage())
+	c.sendToTeam(c.auction.offeringTeam, msg)
+	c.sendToLeaders(msg, c.auction.offeringTeam)
+}
+
+func (c *DraftController) approvePick(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	if c.state != PICK_PENDING_APPROVAL {
+		c.rejectCommand(team, msg, "No pick is waiting for approval")
+		return
+	}
+	c.StartBidding(c.auction.player, c.auction.bid)
+}
+
+func (c *DraftController) rejectPick(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var reject RejectPick
+	if err := json.Unmarshal(msg.SocketMessage.Data, &reject); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	if c.state != PICK_PENDING_APPROVAL {
+		c.rejectCommand(team, msg, "No pick is waiting for approval")
+		return
+	}
+	c.sendToTeam(c.auction.offeringTeam, SocketMessageFrom(PlayerRejected{
+		Player: c.auction.player,
+		Bid:    c.auction.bid,
+		Reason: reject.Reason,
+	}))
+	c.startAuction(&AuctionInfo{
+		offeringTeam: c.auction.offeringTeam,
+	})
+}
+
+func (c *DraftController) setApprovalRequired(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var setting SetApprovalRequired
+	if err := json.Unmarshal(msg.SocketMessage.Data, &setting); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	c.Settings.RequireApproval = setting.Required
+	c.saveSettings()
+	c.broadcast(SocketMessageFrom(SettingsChanged{
+		Settings: c.Settings,
+	}))
+	if !c.Settings.RequireApproval && c.state == PICK_PENDING_APPROVAL {
+		// Nobody is left to approve the pending pick.
+		c.StartBidding(c.auction.player, c.auction.bid)
+	}
+}
+
+func (c *DraftController) setAuctionTiming(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var timing SetAuctionTiming
+	if err := json.Unmarshal(msg.SocketMessage.Data, &timing); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	if timing.AuctionSeconds <= 0 || timing.BidExtensionSeconds <= 0 || timing.ExtensionThresholdSeconds < 0 {
+		c.rejectCommand(team, msg, "Auction and extension durations must be positive")
+		return
+	}
+	// Applies to the next auction and the next bid; the current end time is
+	// left alone.
+	c.Settings.AuctionSeconds = timing.AuctionSeconds
+	c.Settings.BidExtensionSeconds = timing.BidExtensionSeconds
+	c.Settings.ExtensionThresholdSeconds = timing.ExtensionThresholdSeconds
+	c.saveSettings()
+	c.broadcast(SocketMessageFrom(SettingsChanged{
+		Settings: c.Settings,
+	}))
+}
+
+func (c *DraftController) setSpectators(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var spectators SetSpectators
+	if err := json.Unmarshal(msg.SocketMessage.Data, &spectators); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	c.Settings.AllowSpectators = spectators.Allowed
+	c.spectators = spectators.Emails
+	if c.spectators == nil {
+		c.spectators = []string{}
+	}
+	c.saveSettings()
+	if !c.replaying {
+		if err := c.store.SaveSpectators(c.id, c.spectators); err != nil {
+			log.Printf("Unable to save spectators for draft %v: %v", c.id, err)
+		}
+	}
+	c.broadcast(SocketMessageFrom(SettingsChanged{
+		Settings: c.Settings,
+	}))
+	c.reply(team, msg, SocketMessageFrom(Spectators{
+		Allowed: c.Settings.AllowSpectators,
+		Emails:  c.spectators,
+	}))
+	c.disconnectSpectators()
+}
+
+func (c *DraftController) pauseDraft(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var pause PauseDraft
+	if err := json.Unmarshal(msg.SocketMessage.Data, &pause); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	switch c.state {
+	case WAITING_FOR_TEAMS:
+		c.rejectCommand(team, msg, "The draft hasn't started")
+		return
+	case DRAFT_COMPLETE:
+		c.rejectCommand(team, msg, "The draft is complete")
+		return
+	case DRAFT_PAUSED:
+		c.rejectCommand(team, msg, "The draft is already paused")
+		return
+	case WAITING_FOR_PICK:
+		c.auction.remaining = c.auction.nominationEndTime.Sub(c.now())
+	case AUCTION_IN_PROGRESS:
+		c.auction.remaining = c.auction.endTime.Sub(c.now())
+	}
+	log.Println("DRAFT_PAUSED")
+	c.pausedState = c.state
+	c.pauseReason = pause.Reason
+	c.state = DRAFT_PAUSED
+	c.broadcast(SocketMessageFrom(c.GetDraftPausedMessage()))
+}
+
+func (c *DraftController) resumeDraft(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	if c.state != DRAFT_PAUSED {
+		c.rejectCommand(team, msg, "The draft isn't paused")
+		return
+	}
+	c.state = c.pausedState
+	c.pauseReason = ""
+	c.broadcast(SocketMessageFrom(DraftResumed{}))
+	switch c.state {
+	case WAITING_FOR_PICK:
+		if !c.auction.nominationEndTime.IsZero() {
+			c.auction.nominationEndTime = c.now().Add(c.auction.remaining)
+		}
+		c.broadcast(SocketMessageFrom(c.GetWaitingForPickMessage()))
+	case PICK_PENDING_APPROVAL:
+		pending := SocketMessageFrom(c.GetPickPendingApprovalMessage())
+		waiting := SocketMessageFrom(c.GetWaitingForPickMessage())
+		for _, team := range c.Teams {
+			if team == c.auction.offeringTeam {
+				c.sendToTeam(team, pending)
+			} else {
+				c.sendToTeam(team, waiting)
+			}
+		}
+		c.sendToLeaders(pending, c.auction.offeringTeam)
+	case AUCTION_IN_PROGRESS:
+		c.auction.endTime = c.now().Add(c.auction.remaining)
+		c.broadcast(SocketMessageFrom(c.GetAuctionMessage()))
+	}
+}
+
+func (c *DraftController) undoAuction(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	switch c.state {
+	case AUCTION_IN_PROGRESS:
+		c.rejectCommand(team, msg, "Can't undo while an auction is in progress")
+		return
+	case DRAFT_PAUSED:
+		c.rejectCommand(team, msg, "Can't undo while the draft is paused")
+		return
+	}
+	n := len(c.CompletedAuctions)
+	if n == 0 {
+		c.rejectCommand(team, msg, "No auctions have completed")
+		return
+	}
+	last := c.CompletedAuctions[n-1]
+	if !c.replaying {
+		if err := c.store.DeleteAuction(c.id, last.PickNumber); err != nil {
+			log.Printf("Unable to delete pick %v for draft %v: %v", last.PickNumber, c.id, err)
+			c.rejectCommand(team, msg, "Unable to undo the auction")
+			return
+		}
+	}
+	c.dropPlayer(c.teamById(last.WinningTeam), last.Player)
+	c.CompletedAuctions = c.CompletedAuctions[:n-1]
+	undone, _ := json.Marshal(auctionUndoneData{OfferingTeam: last.OfferingTeam})
+	c.journal("", auctionUndoneEvent, undone)
+	c.broadcast(SocketMessageFrom(AuctionUndone{
+		Auction: last,
+	}))
+	if last.OfferingTeam == 0 && c.state != DRAFT_COMPLETE {
+		// Assigned by a leader; whose turn it is doesn't change.
+		c.sendMaxBids()
+		return
+	}
+	next := c.auctionAfterUndo(last.OfferingTeam)
+	if next == nil {
+		// A leader's assignment completed the draft; continue from the
+		// last auction a team offered.
+		next = c.resumeAuction()
+	}
+	if c.state == WAITING_FOR_TEAMS {
+		c.auction = next
+		c.sendMaxBids()
+	} else {
+		c.startAuction(next)
+	}
+}
+
+func (c *DraftController) assignPlayer(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var assign AssignPlayer
+	if err := json.Unmarshal(msg.SocketMessage.Data, &assign); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	if c.state != WAITING_FOR_PICK && c.state != WAITING_FOR_TEAMS {
+		c.rejectCommand(team, msg, "Players can only be assigned between auctions")
+		return
+	}
+	winner := c.teamById(assign.Team)
+	if winner == nil {
+		c.rejectCommand(team, msg, "Unknown team")
+		return
+	}
+	player := c.lookupPlayer(assign.PlayerId)
+	if player == nil {
+		c.rejectCommand(team, msg, "Unknown player")
+		return
+	}
+	if owner := c.ownerOf(player.Id); owner != nil {
+		c.rejectCommand(team, msg, fmt.Sprintf("That player is already on %v", owner.Name))
+		return
+	}
+	if !c.teamHasRoomFor(winner, player) {
+		c.rejectCommand(team, msg, fmt.Sprintf("%v has no room for that player", winner.Name))
+		return
+	}
+	if assign.Salary < c.MinSalary || assign.Salary > c.maxTeamCanBid(winner) {
+		c.rejectCommand(team, msg, fmt.Sprintf("%v can't pay that salary", winner.Name))
+		return
+	}
+	now := c.now()
+	auction := AuctionComplete{
+		Player: &OwnedPlayer{
+			Player: player,
+			Salary: assign.Salary,
+		},
+		WinningTeam: winner.Id,
+		PickNumber:  len(c.CompletedAuctions) + 1,
+		StartTime:   now,
+		EndTime:     now,
+	}
+	if err := c.recordCompletedAuction(&auction); err != nil {
+		c.rejectCommand(team, msg, fmt.Sprintf("Unable to save the assignment: %v", err))
+		return
+	}
+	c.journal("", playerAssignedEvent, nil)
+	c.addPlayer(winner, auction.Player)
+	c.broadcastAuctionComplete(auction)
+	c.removeFromQueues(auction.Player.Id)
+	if !c.teamIsFull(c.auction.offeringTeam) {
+		c.sendMaxBids()
+		return
+	}
+	// The assignment filled the team whose turn it was.
+	next := c.nextAuction(c.auction)
+	switch {
+	case next == nil:
+		c.finishDraft()
+	case c.state == WAITING_FOR_TEAMS:
+		c.auction = next
+	default:
+		c.startAuction(next)
+	}
+}
+
+func (c *DraftController) kickConnection(team *Team, msg *TeamMessage) {
+	if !c.checkLeader(team, msg) {
+		return
+	}
+	var kick KickConnection
+	if err := json.Unmarshal(msg.SocketMessage.Data, &kick); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	c.closeConnections(kick.Email)
+}
+
+// Closes every connection email has open, whether they own a team or not.
+func (c *DraftController) closeConnections(email string) {
+	if owner, ok := c.owners[email]; ok {
+		for conn, ch := range owner.connections {
+			if conn.User.Email == email {
+				log.Printf("Kicking connection %v", conn)
+				delete(owner.connections, conn)
+				close(ch)
+			}
+		}
+		c.broadcast(SocketMessageFrom(c.GetJoinLeaveMessage()))
+		if c.state == WAITING_FOR_PICK && owner == c.auction.offeringTeam {
+			c.nominateIfDisconnected()
+		}
+		return
+	}
+	for conn, ch := range c.observers {
+		if conn.User.Email == email {
+			log.Printf("Kicking connection %v", conn)
+			delete(c.observers, conn)
+			close(ch)
+		}
+	}
+}
@@ -9,9 +9,14 @@ import (
 // Messages sent over the websocket will be of this format. Type
 // will describe the type of Data and Data will be one of the
 // substructures below.
+//
+// Messages sent by the server carry Seq, which increases by one with every
+// message the controller sends to any team. Clients reconnect with the last
+// Seq they saw to receive only the messages they missed.
 type SocketMessage struct {
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data"`
+	Seq  int64           `json:"seq,omitempty"`
 }
 
 func SocketMessageFrom(msg interface{}) *SocketMessage {
@@ -27,9 +32,17 @@ func SocketMessageFrom(msg interface{}) *SocketMessage {
 }
 
 // Sent when a new connection is registered detailing the teams involved in the draft.
+// Team is zero for spectators and leaders who don't own a team. Leader is
+// true if the connection may send leader commands. Keepers can't change
+// once Started is true. Epoch identifies the
+// controller that assigned the message's Seq and must be sent along with
+// Seq when reconnecting.
 type DraftSummary struct {
 	*DraftController
-	Team TeamId `json:"team"`
+	Team    TeamId `json:"team"`
+	Leader  bool   `json:"leader"`
+	Started bool   `json:"started"`
+	Epoch   int64  `json:"epoch"`
 }
 
 // A new team has joined or left the draft. Included are the list
@@ -40,58 +53,234 @@ type TeamJoinLeaveMessage struct {
 	Disconnected []TeamId `json:"disconnected"`
 }
 
-// Sent to all teams not picking the next player.
+// Sent to all teams not picking the next player. EndTime is when the
+// nomination clock runs out, or zero if nominations aren't timed.
 type WaitingForPick struct {
-	Team TeamId `json:"team"`
+	Team    TeamId    `json:"team"`
+	EndTime time.Time `json:"end_time"`
 }
 
-// The player and opening bid the team has chosen. Sent to the
-// draft leaders for approval.
+// Sent by the picking team with the id of the player it has chosen and
+// the opening bid.
 type Pick struct {
-	Player *Player `json:"player"`
-	Bid    int     `json:"bid"`
+	PlayerId int64 `json:"player_id"`
+	Bid      int   `json:"bid"`
 }
 
-// Sent to team in response to PickPlayerRequest.
+// Sent to the picking team and the draft leaders when a Pick is
+// waiting for the leaders to approve it.
 type PickPendingApproval struct {
+	Team   TeamId  `json:"team"`
 	Player *Player `json:"player"`
 	Bid    int     `json:"bid"`
 }
 
+// Sent by a draft leader to approve the pending pick and start bidding.
+type ApprovePick struct {
+}
+
+// Sent by a draft leader to reject the pending pick. The picking team
+// receives a PlayerRejected with Reason.
+type RejectPick struct {
+	Reason string `json:"reason"`
+}
+
+// Sent by a draft leader to turn leader approval of picks on or off.
+type SetApprovalRequired struct {
+	Required bool `json:"required"`
+}
+
+// Sent by a draft leader to change how long auctions last. See
+// DraftSettings.
+type SetAuctionTiming struct {
+	AuctionSeconds            int `json:"auction_seconds"`
+	BidExtensionSeconds       int `json:"bid_extension_seconds"`
+	ExtensionThresholdSeconds int `json:"extension_threshold_seconds"`
+}
+
+// Sent to all teams when the draft's settings change.
+type SettingsChanged struct {
+	Settings DraftSettings `json:"settings"`
+}
+
+// Sent by a draft leader to control who may spectate. If Allowed is true
+// and Emails is empty anyone may spectate.
+type SetSpectators struct {
+	Allowed bool     `json:"allowed"`
+	Emails  []string `json:"emails"`
+}
+
+// Sent to a draft leader in response to SetSpectators.
+type Spectators struct {
+	Allowed bool     `json:"allowed"`
+	Emails  []string `json:"emails"`
+}
+
+// Sent by a draft leader to put a player on a team's roster without an
+// auction.
+type AssignPlayer struct {
+	PlayerId int64  `json:"player_id"`
+	Team     TeamId `json:"team"`
+	Salary   int    `json:"salary"`
+}
+
+// Sent by a draft leader before the draft starts to add a keeper to a
+// team's roster.
+type SetKeeper struct {
+	Team     TeamId `json:"team"`
+	PlayerId int64  `json:"player_id"`
+	Salary   int    `json:"salary"`
+}
+
+// Sent by a draft leader before the draft starts to remove a keeper from a
+// team's roster.
+type RemoveKeeper struct {
+	Team     TeamId `json:"team"`
+	PlayerId int64  `json:"player_id"`
+}
+
+// Sent to all teams when a keeper is added to a team's roster.
+type KeeperAdded struct {
+	Team   TeamId       `json:"team"`
+	Player *OwnedPlayer `json:"player"`
+}
+
+// Sent to all teams when a keeper is removed from a team's roster.
+type KeeperRemoved struct {
+	Team     TeamId `json:"team"`
+	PlayerId int64  `json:"player_id"`
+}
+
+// Sent by a draft leader to close every connection belonging to Email.
+type KickConnection struct {
+	Email string `json:"email"`
+}
+
+// Sent by a draft leader to pause the draft.
+type PauseDraft struct {
+	Reason string `json:"reason"`
+}
+
+// Sent by a draft leader to resume a paused draft.
+type ResumeDraft struct {
+}
+
+// Sent to all teams when the draft is paused. Picks and bids are rejected
+// and the auction clock is stopped until the draft is resumed.
+type DraftPaused struct {
+	Reason string `json:"reason"`
+}
+
+// Sent to all teams when the draft resumes, followed by the message
+// describing the state the draft resumed in.
+type DraftResumed struct {
+}
+
+// Sent when a leader command is rejected.
+type CommandRejected struct {
+	Command string `json:"command"`
+	Reason  string `json:"reason"`
+}
+
 // Sent to the picking team if the draft leaders don't approve the
-// player along with a reason.
+// player along with a reason. Rule names the draft rule the pick broke, if
+// any.
 type PlayerRejected struct {
 	Player *Player `json:"player"`
 	Bid    int     `json:"bid"`
+	Rule   string  `json:"rule,omitempty"`
 	Reason string  `json:"reason"`
 }
 
 // Sent to all teams to indicate the current state of the auction.
+// MinBid is the smallest bid that will be accepted next.
 type Auction struct {
 	Player  *Player   `json:"player"`
 	Team    TeamId    `json:"team"`
 	Bid     int       `json:"bid"`
+	MinBid  int       `json:"min_bid"`
 	EndTime time.Time `json:"end_time"`
 }
 
 // Sent by any team to bid on a player.
 type Bid struct {
+	PlayerId int64 `json:"player_id"`
+	Bid      int   `json:"bid"`
+}
+
+// A player a team wants to nominate and the opening bid to nominate them
+// at.
+type QueueEntry struct {
 	Player *Player `json:"player"`
 	Bid    int     `json:"bid"`
 }
 
-// Sent when a bid is rejected.
+// Sent by a team to replace its nomination queue with the picks it wants
+// to make, in order. The server nominates from the queue when the team is
+// disconnected or its nomination clock runs out.
+type SetNominationQueue struct {
+	Entries []*Pick `json:"entries"`
+}
+
+// Sent to a team with the current contents of its nomination queue. Sold
+// players are removed from the queue automatically.
+type NominationQueue struct {
+	Entries []*QueueEntry `json:"entries"`
+}
+
+// Sent by a team to have the server bid on its behalf, only as much as it
+// takes to lead, up to Max. Max is never revealed to other teams.
+type ProxyBid struct {
+	PlayerId int64 `json:"player_id"`
+	Max      int   `json:"max"`
+}
+
+// Sent to a team when its ProxyBid has been accepted.
+type ProxyBidAccepted struct {
+	Player *Player `json:"player"`
+	Max    int     `json:"max"`
+}
+
+// Sent when a bid is rejected. Rule names the draft rule the bid broke, if
+// any.
 type BidRejected struct {
 	Player *Player `json:"player"`
 	Bid    int     `json:"bid"`
+	Rule   string  `json:"rule,omitempty"`
 	Reason string  `json:"reason"`
 }
 
-// Sent to all teams when an auction has completed.
+// Sent to a team with the most it can bid, whenever that may have
+// changed. While an auction is in progress Player is the player being
+// auctioned and Max is 0 if the team has no room for them.
+type MaxBid struct {
+	Player int64 `json:"player,omitempty"`
+	Max    int   `json:"max"`
+}
+
+// Sent to all teams when an auction has completed. OfferingTeam is zero for
+// players a draft leader assigned to a team. Lineup is the winning team's
+// lineup after adding the player; it's omitted from the picks in
+// DraftSummary, where each team has its current lineup.
 type AuctionComplete struct {
 	Player       *OwnedPlayer `json:"player"`
 	OfferingTeam TeamId       `json:"offering_team"`
 	WinningTeam  TeamId       `json:"winning_team"`
+	PickNumber   int          `json:"pick_number"`
+	StartTime    time.Time    `json:"start_time"`
+	EndTime      time.Time    `json:"end_time"`
+	Lineup       *Lineup      `json:"lineup,omitempty"`
+}
+
+// Sent by a draft leader to reverse the most recently completed auction.
+type UndoAuction struct {
+}
+
+// Sent to all teams when a completed auction has been reversed. The player
+// is removed from the winning team's roster and nominations resume with
+// the team that offered the auction.
+type AuctionUndone struct {
+	Auction *AuctionComplete `json:"auction"`
 }
 
 type DraftComplete struct {
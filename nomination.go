@@ -0,0 +1,215 @@
+package tnpldraft
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Values for DraftSettings.NominationTimeout.
+const (
+	// Nominate the best available player from the draft's ranked pool
+	// that fits on the team's roster, at the minimum salary.
+	AutoNominate = "auto"
+	// Move on to the next team.
+	SkipNomination = "skip"
+)
+
+// Minimum salary for drafts that don't configure one.
+const defaultMinSalary = 50
+
+// Returns the minimum salary of a draft configured with minSalary.
+func effectiveMinSalary(minSalary int) int {
+	if minSalary == 0 {
+		return defaultMinSalary
+	}
+	return minSalary
+}
+
+func (c *DraftController) nominationExpired() <-chan time.Time {
+	if c.state != WAITING_FOR_PICK || c.auction.nominationEndTime.IsZero() {
+		return make(chan time.Time)
+	}
+	return time.After(c.auction.nominationEndTime.Sub(time.Now()))
+}
+
+// The nomination made for a team that didn't pick in time or isn't
+// connected, as journaled. PlayerId is 0 if the team was skipped, so
+// replaying the journal doesn't depend on queues that changed since.
+type nominationData struct {
+	PlayerId int64 `json:"player_id"`
+	Bid      int   `json:"bid"`
+}
+
+func (c *DraftController) nominationTimedOut() {
+	team := c.auction.offeringTeam
+	player, bid := c.queuedNomination(team)
+	if player == nil && c.Settings.NominationTimeout == AutoNominate {
+		player, bid = c.rankedNomination(team)
+		if player == nil {
+			log.Printf("No ranked player fits team %v", team.Name)
+		}
+	}
+	c.journalNomination(nominationExpiredEvent, player, bid)
+	c.nominate(team, player, bid)
+}
+
+// Returns the best available player from the draft's ranked pool that
+// fits on team's roster, at the minimum salary, or nil if none does.
+func (c *DraftController) rankedNomination(team *Team) (*Player, int) {
+	for _, player := range c.rankedPool {
+		if c.checkRules(&Action{
+			Kind:   NominateAction,
+			Team:   team,
+			Player: player,
+			Amount: c.MinSalary,
+		}) == nil {
+			return player, c.MinSalary
+		}
+	}
+	return nil, 0
+}
+
+func (c *DraftController) journalNomination(eventType string, player *Player, bid int) {
+	data := nominationData{}
+	if player != nil {
+		data.PlayerId = player.Id
+		data.Bid = bid
+	}
+	encoded, _ := json.Marshal(data)
+	c.journal("", eventType, encoded)
+}
+
+// Starts bidding on player for team, or moves on to the next team if
+// player is nil.
+func (c *DraftController) nominate(team *Team, player *Player, bid int) {
+	if player == nil {
+		log.Printf("Skipping team %v", team.Name)
+		c.startAuction(c.nextAuction(c.auction))
+		return
+	}
+	log.Printf("Nominating %v %v for team %v", player.Firstname, player.Lastname, team.Name)
+	c.StartBidding(player, bid)
+}
+
+// Applies a journaled nomination of the offering team.
+func (c *DraftController) replayNomination(event *JournalEvent) {
+	var data nominationData
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		log.Printf("Journal event %v: invalid nomination: %v", event.Seq, err)
+		return
+	}
+	var player *Player
+	if data.PlayerId != 0 {
+		if player = c.lookupPlayer(data.PlayerId); player == nil {
+			log.Printf("Journal event %v: unknown player %v", event.Seq, data.PlayerId)
+			return
+		}
+	}
+	if player == nil && event.Type == autoNominateEvent {
+		return
+	}
+	c.nominate(c.auction.offeringTeam, player, data.Bid)
+}
+
+func (team *Team) GetNominationQueueMessage() NominationQueue {
+	entries := team.queue
+	if entries == nil {
+		entries = []*QueueEntry{}
+	}
+	return NominationQueue{
+		Entries: entries,
+	}
+}
+
+func (c *DraftController) saveQueue(team *Team) {
+	if c.replaying {
+		return
+	}
+	if err := c.store.SaveQueue(team.Id, team.queue); err != nil {
+		log.Printf("Unable to save nomination queue for team %v: %v", team.Name, err)
+	}
+}
+
+func (c *DraftController) setNominationQueue(team *Team, msg *TeamMessage) {
+	var queue SetNominationQueue
+	if err := json.Unmarshal(msg.SocketMessage.Data, &queue); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	entries := make([]*QueueEntry, 0, len(queue.Entries))
+	for _, pick := range queue.Entries {
+		if pick == nil {
+			continue
+		}
+		player := c.lookupPlayer(pick.PlayerId)
+		if player == nil || c.isOwned(player.Id) {
+			continue
+		}
+		entries = append(entries, &QueueEntry{
+			Player: player,
+			Bid:    pick.Bid,
+		})
+	}
+	team.queue = entries
+	c.saveQueue(team)
+	c.sendToTeam(team, SocketMessageFrom(team.GetNominationQueueMessage()))
+}
+
+// Drops playerId from every team's nomination queue.
+func (c *DraftController) removeFromQueues(playerId int64) {
+	for _, team := range c.Teams {
+		entries := team.queue[:0]
+		for _, entry := range team.queue {
+			if entry.Player.Id != playerId {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) == len(team.queue) {
+			continue
+		}
+		team.queue = entries
+		c.saveQueue(team)
+		c.sendToTeam(team, SocketMessageFrom(team.GetNominationQueueMessage()))
+	}
+}
+
+// Returns the first player in team's queue the draft's rules allow, and
+// the bid to open at. Returns nil if there's no such player.
+func (c *DraftController) queuedNomination(team *Team) (*Player, int) {
+	for _, entry := range team.queue {
+		bid := entry.Bid
+		if bid < c.MinSalary {
+			bid = c.MinSalary
+		}
+		if c.checkRules(&Action{
+			Kind:   NominateAction,
+			Team:   team,
+			Player: entry.Player,
+			Amount: bid,
+		}) == nil {
+			return entry.Player, bid
+		}
+	}
+	return nil, 0
+}
+
+// Nominates from the offering team's queue if none of its owners are
+// connected to pick.
+func (c *DraftController) nominateIfDisconnected() {
+	// While replaying the journal records whether this happened.
+	if c.replaying {
+		return
+	}
+	team := c.auction.offeringTeam
+	if len(team.connections) > 0 || len(team.queue) == 0 {
+		return
+	}
+	player, bid := c.queuedNomination(team)
+	if player == nil {
+		log.Printf("Nothing in the queue of disconnected team %v can be nominated", team.Name)
+		return
+	}
+	c.journalNomination(autoNominateEvent, player, bid)
+	c.nominate(team, player, bid)
+}
@@ -0,0 +1,122 @@
+package tnpldraft
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// A maximum bid the server bids up to on a team's behalf.
+type proxyBid struct {
+	team *Team
+	max  int
+}
+
+func (auction *AuctionInfo) proxyFor(team *Team) *proxyBid {
+	for _, proxy := range auction.proxies {
+		if proxy.team == team {
+			return proxy
+		}
+	}
+	return nil
+}
+
+func (auction *AuctionInfo) setProxy(team *Team, max int) {
+	for i, proxy := range auction.proxies {
+		if proxy.team == team {
+			auction.proxies = append(auction.proxies[:i], auction.proxies[i+1:]...)
+			break
+		}
+	}
+	auction.proxies = append(auction.proxies, &proxyBid{
+		team: team,
+		max:  max,
+	})
+}
+
+func (c *DraftController) handleProxyBid(team *Team, msg *TeamMessage) {
+	var proxy ProxyBid
+	if err := json.Unmarshal(msg.SocketMessage.Data, &proxy); err != nil {
+		log.Println("Invalid message")
+		return
+	}
+	player := c.lookupPlayer(proxy.PlayerId)
+	reject := func(reason string) {
+		c.sendToTeam(team, SocketMessageFrom(BidRejected{
+			Player: player,
+			Bid:    proxy.Max,
+			Reason: reason,
+		}))
+	}
+	if c.state == DRAFT_PAUSED {
+		reject("The draft is paused")
+		return
+	}
+	if c.state != AUCTION_IN_PROGRESS {
+		reject("No auction is in progress")
+		return
+	}
+	if player == nil || player.Id != c.auction.player.Id {
+		reject("Player is not up for auction")
+		return
+	}
+	if rejection := c.checkRules(&Action{
+		Kind:   ProxyBidAction,
+		Team:   team,
+		Player: player,
+		Amount: proxy.Max,
+	}); rejection != nil {
+		c.sendToTeam(team, SocketMessageFrom(BidRejected{
+			Player: player,
+			Bid:    proxy.Max,
+			Rule:   rejection.Rule,
+			Reason: rejection.Reason,
+		}))
+		return
+	}
+	c.auction.setProxy(team, proxy.Max)
+	c.sendToTeam(team, SocketMessageFrom(ProxyBidAccepted{
+		Player: c.auction.player,
+		Max:    proxy.Max,
+	}))
+	c.resolveProxies()
+}
+
+// Bids on behalf of proxies so the high bidder can't be outbid by any of
+// them, in a single broadcast bid. The proxy with the highest max wins,
+// preferring the earliest set on ties, and bids one increment over the
+// next highest max or the current bid, capped at its own max.
+func (c *DraftController) resolveProxies() {
+	minBid := c.minimumBid()
+	var best *proxyBid
+	outbid := false
+	for _, proxy := range c.auction.proxies {
+		if proxy.team != c.auction.highBidder {
+			if proxy.max < minBid {
+				continue
+			}
+			outbid = true
+		}
+		if best == nil || proxy.max > best.max {
+			best = proxy
+		}
+	}
+	if !outbid {
+		return
+	}
+	// What the winner has to beat: every other proxy that could bid and
+	// the current bid.
+	second := c.auction.bid
+	for _, proxy := range c.auction.proxies {
+		if proxy != best && proxy.max > second && (proxy.max >= minBid || proxy.team == c.auction.highBidder) {
+			second = proxy.max
+		}
+	}
+	bid := second + c.Settings.bidIncrement(second)
+	if bid < minBid {
+		bid = minBid
+	}
+	if bid > best.max {
+		bid = best.max
+	}
+	c.acceptBid(best.team, bid)
+}
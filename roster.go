@@ -0,0 +1,184 @@
+package tnpldraft
+
+import (
+	"sort"
+	"strings"
+)
+
+// Which base positions make a player eligible for a slot: "2B|SS" for any
+// of the listed positions, "!P" for any position except those listed and
+// "*" for any position. A slot without a rule is filled by players whose
+// base positions include the slot itself.
+type SlotRule string
+
+// Slot rules indexed by slot.
+type SlotRules map[string]SlotRule
+
+// Slot rules for drafts that don't define their own.
+var DefaultSlotRules = SlotRules{
+	"MI": "2B|SS",
+	"CI": "1B|3B",
+	"U":  "!P",
+}
+
+func (rule SlotRule) allows(base []string) bool {
+	expr := string(rule)
+	if expr == "*" {
+		return len(base) > 0
+	}
+	exclude := strings.HasPrefix(expr, "!")
+	listed := strings.Split(strings.TrimPrefix(expr, "!"), "|")
+	for _, pos := range base {
+		found := false
+		for _, l := range listed {
+			if pos == l {
+				found = true
+				break
+			}
+		}
+		if found != exclude {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns base along with every slot that rules makes base eligible for.
+func (rules SlotRules) Positions(base []string) []string {
+	positions := append([]string{}, base...)
+	slots := make([]string, 0, len(rules))
+	for slot := range rules {
+		slots = append(slots, slot)
+	}
+	sort.Strings(slots)
+	for _, slot := range slots {
+		if rules[slot].allows(base) && !contains(positions, slot) {
+			positions = append(positions, slot)
+		}
+	}
+	return positions
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Where a team's players are slotted and which of its slots are still open.
+type Lineup struct {
+	Slots map[int64]string `json:"slots"` // indexed by player id
+	Open  []string         `json:"open"`
+}
+
+// Expands required into one slot per roster spot, grouped by position.
+func rosterSlots(required map[string]int) []string {
+	positions := make([]string, 0, len(required))
+	for pos := range required {
+		positions = append(positions, pos)
+	}
+	sort.Strings(positions)
+	slots := []string{}
+	for _, pos := range positions {
+		for i := 0; i < required[pos]; i++ {
+			slots = append(slots, pos)
+		}
+	}
+	return slots
+}
+
+func eligibleFor(player *Player, slot string) bool {
+	return contains(player.Positions, slot)
+}
+
+// Assigns each of players to a slot they're eligible for, using Kuhn's
+// augmenting path algorithm for maximum bipartite matching. Returns the
+// index of each player's slot, or -1 for players that couldn't be
+// assigned, and whether every player was assigned.
+func assignSlots(players []*Player, slots []string) ([]int, bool) {
+	playerInSlot := make([]int, len(slots))
+	for s := range playerInSlot {
+		playerInSlot[s] = -1
+	}
+	var augment func(p int, visited []bool) bool
+	augment = func(p int, visited []bool) bool {
+		for s, slot := range slots {
+			if visited[s] || !eligibleFor(players[p], slot) {
+				continue
+			}
+			visited[s] = true
+			if playerInSlot[s] == -1 || augment(playerInSlot[s], visited) {
+				playerInSlot[s] = p
+				return true
+			}
+		}
+		return false
+	}
+	complete := true
+	for p := range players {
+		if !augment(p, make([]bool, len(slots))) {
+			complete = false
+		}
+	}
+	slotOf := make([]int, len(players))
+	for p := range slotOf {
+		slotOf[p] = -1
+	}
+	for s, p := range playerInSlot {
+		if p != -1 {
+			slotOf[p] = s
+		}
+	}
+	return slotOf, complete
+}
+
+func (c *DraftController) teamHasRoomFor(team *Team, player *Player) bool {
+	if len(team.Players) >= len(c.slots) {
+		return false
+	}
+	players := make([]*Player, len(team.Players), len(team.Players)+1)
+	for i, ownedPlayer := range team.Players {
+		players[i] = ownedPlayer.Player
+	}
+	players = append(players, player)
+	_, ok := assignSlots(players, c.slots)
+	return ok
+}
+
+// Slots team's players and records the slots left open. Players that
+// can't be slotted are left without one.
+func (c *DraftController) assignTeamSlots(team *Team) {
+	players := make([]*Player, len(team.Players))
+	for i, ownedPlayer := range team.Players {
+		players[i] = ownedPlayer.Player
+	}
+	slotOf, _ := assignSlots(players, c.slots)
+	filled := make([]bool, len(c.slots))
+	for i, ownedPlayer := range team.Players {
+		ownedPlayer.Slot = ""
+		if s := slotOf[i]; s != -1 {
+			ownedPlayer.Slot = c.slots[s]
+			filled[s] = true
+		}
+	}
+	team.OpenSlots = []string{}
+	for s, slot := range c.slots {
+		if !filled[s] {
+			team.OpenSlots = append(team.OpenSlots, slot)
+		}
+	}
+}
+
+func (c *DraftController) lineup(team *Team) *Lineup {
+	lineup := &Lineup{
+		Slots: make(map[int64]string, len(team.Players)),
+		Open:  team.OpenSlots,
+	}
+	for _, player := range team.Players {
+		lineup.Slots[player.Id] = player.Slot
+	}
+	return lineup
+}
@@ -0,0 +1,221 @@
+package tnpldraft
+
+import "fmt"
+
+// Kinds of actions rules are checked against.
+type ActionKind int
+
+const (
+	NominateAction ActionKind = iota
+	BidAction
+	ProxyBidAction
+)
+
+// A team nominating, bidding or setting a proxy bid on Player for Amount.
+type Action struct {
+	Kind   ActionKind
+	Team   *Team
+	Player *Player
+	Amount int
+}
+
+// Why a Rule disallowed an action.
+type Rejection struct {
+	Rule   string
+	Reason string
+}
+
+// A Rule decides whether teams may take an action.
+type Rule interface {
+	// The name clients are told when the rule rejects an action.
+	Name() string
+	// Returns nil if action is allowed, or why it isn't.
+	Check(c *DraftController, action *Action) *Rejection
+}
+
+// Rules every draft enforces, in the order they're checked.
+var builtinRules = []Rule{
+	availabilityRule{},
+	incrementRule{},
+	budgetRule{},
+	rosterRule{},
+}
+
+// Kinds of rules a draft may turn on through its configuration.
+const (
+	MaxPerMLBTeamRule = "max_per_mlb_team"
+	MaxPitchersRule   = "max_pitchers"
+	OpeningBidCapRule = "opening_bid_cap"
+)
+
+// A rule a draft has turned on. Limit is the rule's parameter. Positions
+// is which base positions MaxPitchersRule counts as pitchers; when empty
+// it counts players eligible for a "P" slot.
+type RuleConfig struct {
+	Kind      string   `json:"kind"`
+	Limit     int      `json:"limit"`
+	Positions SlotRule `json:"positions,omitempty"`
+}
+
+func newRule(config *RuleConfig) (Rule, error) {
+	switch config.Kind {
+	case MaxPerMLBTeamRule:
+		return maxPerMLBTeamRule{max: config.Limit}, nil
+	case MaxPitchersRule:
+		return maxPitchersRule{max: config.Limit, pitchers: config.Positions}, nil
+	case OpeningBidCapRule:
+		return openingBidCapRule{max: config.Limit}, nil
+	}
+	return nil, fmt.Errorf("unknown rule %q", config.Kind)
+}
+
+// Returns nil if every rule allows action, or the first rejection.
+func (c *DraftController) checkRules(action *Action) *Rejection {
+	for _, rule := range c.rules {
+		if rejection := rule.Check(c, action); rejection != nil {
+			return rejection
+		}
+	}
+	return nil
+}
+
+func rejectedBy(rule Rule, format string, args ...interface{}) *Rejection {
+	return &Rejection{
+		Rule:   rule.Name(),
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// Players already on a team can't be auctioned again.
+type availabilityRule struct{}
+
+func (r availabilityRule) Name() string { return "availability" }
+
+func (r availabilityRule) Check(c *DraftController, action *Action) *Rejection {
+	if action.Kind != NominateAction {
+		// The player was available when nominated.
+		return nil
+	}
+	if owner := c.ownerOf(action.Player.Id); owner != nil {
+		return rejectedBy(r, "%v %v is already on %v", action.Player.Firstname, action.Player.Lastname, owner.Name)
+	}
+	return nil
+}
+
+// Opening bids must be at least the minimum salary and every later bid
+// must raise by at least the bid increment.
+type incrementRule struct{}
+
+func (r incrementRule) Name() string { return "increment" }
+
+func (r incrementRule) Check(c *DraftController, action *Action) *Rejection {
+	switch {
+	case action.Kind == NominateAction:
+		if action.Amount < c.MinSalary {
+			return rejectedBy(r, "Bid must be at least %v", formatMoney(c.MinSalary))
+		}
+	case action.Kind == ProxyBidAction && action.Team == c.auction.highBidder:
+		if action.Amount <= c.auction.bid {
+			return rejectedBy(r, "Maximum bid must be more than your current bid")
+		}
+	default:
+		if minBid := c.minimumBid(); action.Amount < minBid {
+			return rejectedBy(r, "Bid must be at least %v", formatMoney(minBid))
+		}
+	}
+	return nil
+}
+
+// Teams can't bid, or open bidding, at more than they could pay while
+// still filling their roster.
+type budgetRule struct{}
+
+func (r budgetRule) Name() string { return "budget" }
+
+func (r budgetRule) Check(c *DraftController, action *Action) *Rejection {
+	if maxBid := c.maxTeamCanBid(action.Team); action.Amount > maxBid {
+		return rejectedBy(r, "You cannot bid more than %v", formatMoney(maxBid))
+	}
+	return nil
+}
+
+// Teams must have a roster slot the player is eligible for.
+type rosterRule struct{}
+
+func (r rosterRule) Name() string { return "roster" }
+
+func (r rosterRule) Check(c *DraftController, action *Action) *Rejection {
+	if !c.teamHasRoomFor(action.Team, action.Player) {
+		return rejectedBy(r, "No room for player on your roster")
+	}
+	return nil
+}
+
+// Teams may own at most max players from any one MLB team.
+type maxPerMLBTeamRule struct {
+	max int
+}
+
+func (r maxPerMLBTeamRule) Name() string { return MaxPerMLBTeamRule }
+
+func (r maxPerMLBTeamRule) Check(c *DraftController, action *Action) *Rejection {
+	count := 0
+	for _, player := range action.Team.Players {
+		if player.Mlbteam == action.Player.Mlbteam {
+			count++
+		}
+	}
+	if count >= r.max {
+		return rejectedBy(r, "You already have %v players from %v", count, action.Player.Mlbteam)
+	}
+	return nil
+}
+
+// Teams may own at most max pitchers: players whose base positions
+// pitchers allows or, without it, players eligible for a "P" slot.
+type maxPitchersRule struct {
+	max      int
+	pitchers SlotRule
+}
+
+func (r maxPitchersRule) Name() string { return MaxPitchersRule }
+
+func (r maxPitchersRule) isPitcher(player *Player) bool {
+	if r.pitchers == "" {
+		return contains(player.Positions, "P")
+	}
+	return r.pitchers.allows(player.BasePositions)
+}
+
+func (r maxPitchersRule) Check(c *DraftController, action *Action) *Rejection {
+	if !r.isPitcher(action.Player) {
+		return nil
+	}
+	count := 0
+	for _, player := range action.Team.Players {
+		if r.isPitcher(player.Player) {
+			count++
+		}
+	}
+	if count >= r.max {
+		return rejectedBy(r, "You already have %v pitchers", count)
+	}
+	return nil
+}
+
+// Teams may not nominate players with an opening bid above max, a fixed
+// limit the league sets for every team. Nominating above a team's own max
+// bid needs no optional rule: budgetRule rejects it, like any other bid
+// the team couldn't pay.
+type openingBidCapRule struct {
+	max int
+}
+
+func (r openingBidCapRule) Name() string { return OpeningBidCapRule }
+
+func (r openingBidCapRule) Check(c *DraftController, action *Action) *Rejection {
+	if action.Kind == NominateAction && action.Amount > r.max {
+		return rejectedBy(r, "Opening bids can't be more than %v", formatMoney(r.max))
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+-- Schema used by MySQLStore. The player and mlbteam tables hold the
+-- player catalog shared by every draft.
+
+CREATE TABLE IF NOT EXISTS mlbteam (
+  id INT NOT NULL AUTO_INCREMENT,
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS player (
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  firstname VARCHAR(64) NOT NULL,
+  lastname VARCHAR(64) NOT NULL,
+  mlbteam_id INT NOT NULL,
+  pitcher BOOL NOT NULL DEFAULT FALSE,
+  catcher BOOL NOT NULL DEFAULT FALSE,
+  firstbase BOOL NOT NULL DEFAULT FALSE,
+  secondbase BOOL NOT NULL DEFAULT FALSE,
+  thirdbase BOOL NOT NULL DEFAULT FALSE,
+  shortstop BOOL NOT NULL DEFAULT FALSE,
+  outfield BOOL NOT NULL DEFAULT FALSE,
+  utility BOOL NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (id),
+  FOREIGN KEY (mlbteam_id) REFERENCES mlbteam (id)
+);
+
+-- Base positions beyond those with a column in player, such as SP, RP or
+-- DH.
+CREATE TABLE IF NOT EXISTS player_position (
+  player_id BIGINT NOT NULL,
+  position VARCHAR(8) NOT NULL,
+  PRIMARY KEY (player_id, position),
+  FOREIGN KEY (player_id) REFERENCES player (id)
+);
+
+CREATE TABLE IF NOT EXISTS draft (
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  name VARCHAR(128) NOT NULL,
+  salary_cap INT NOT NULL,
+  -- Smallest salary a player may be paid, and the largest or 0 for no
+  -- limit, in cents.
+  min_salary INT NOT NULL DEFAULT 50,
+  max_salary INT NOT NULL DEFAULT 0,
+  -- Settings leaders may change during the draft. See DraftSettings.
+  require_approval BOOL NOT NULL DEFAULT FALSE,
+  auction_seconds INT NOT NULL DEFAULT 30,
+  bid_extension_seconds INT NOT NULL DEFAULT 20,
+  extension_threshold_seconds INT NOT NULL DEFAULT 20,
+  nomination_seconds INT NOT NULL DEFAULT 0,
+  nomination_timeout VARCHAR(16) NOT NULL DEFAULT 'skip',
+  allow_spectators BOOL NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (id)
+);
+
+-- Tiered minimum raises. A raise over a bid of at least min_bid must be at
+-- least increment, using the tier with the largest matching min_bid.
+CREATE TABLE IF NOT EXISTS draft_bid_increment (
+  draft_id BIGINT NOT NULL,
+  min_bid INT NOT NULL,
+  increment INT NOT NULL,
+  PRIMARY KEY (draft_id, min_bid),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+-- Optional rules checked against nominations and bids, on top of the rules
+-- every draft enforces. kind is one of max_per_mlb_team, max_pitchers or
+-- opening_bid_cap. rule_positions is which base positions max_pitchers
+-- counts, like a draft_slot rule; '' counts players eligible for a P slot.
+CREATE TABLE IF NOT EXISTS draft_rule (
+  draft_id BIGINT NOT NULL,
+  kind VARCHAR(32) NOT NULL,
+  rule_limit INT NOT NULL,
+  rule_positions VARCHAR(64) NOT NULL DEFAULT '',
+  PRIMARY KEY (draft_id, kind),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+-- Which base positions make a player eligible for a draft's composite
+-- slots, for example MI = '2B|SS', U = '!P' or BN = '*'. See SlotRule.
+-- Drafts without rows use DefaultSlotRules.
+CREATE TABLE IF NOT EXISTS draft_slot (
+  draft_id BIGINT NOT NULL,
+  slot VARCHAR(8) NOT NULL,
+  eligible VARCHAR(64) NOT NULL,
+  PRIMARY KEY (draft_id, slot),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+-- Number of roster slots of each position a team must fill.
+CREATE TABLE IF NOT EXISTS draft_position (
+  draft_id BIGINT NOT NULL,
+  position VARCHAR(8) NOT NULL,
+  count INT NOT NULL,
+  PRIMARY KEY (draft_id, position),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+CREATE TABLE IF NOT EXISTS draft_leader (
+  draft_id BIGINT NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  PRIMARY KEY (draft_id, email),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+-- People allowed to spectate a draft that allows spectators. Anyone may
+-- spectate if there are no rows for the draft.
+CREATE TABLE IF NOT EXISTS draft_spectator (
+  draft_id BIGINT NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  PRIMARY KEY (draft_id, email),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+-- Teams in a draft. Nominations proceed in ascending draft_order.
+CREATE TABLE IF NOT EXISTS team (
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  draft_id BIGINT NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  draft_order INT NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+CREATE TABLE IF NOT EXISTS team_owner (
+  team_id BIGINT NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  PRIMARY KEY (team_id, email),
+  FOREIGN KEY (team_id) REFERENCES team (id)
+);
+
+-- Keepers: players on a team's roster before the draft begins. Players won
+-- during the draft are recorded in draft_pick.
+CREATE TABLE IF NOT EXISTS roster (
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  team_id BIGINT NOT NULL,
+  player_id BIGINT NOT NULL,
+  salary INT NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (team_id) REFERENCES team (id),
+  FOREIGN KEY (player_id) REFERENCES player (id)
+);
+
+-- Players won at auction or assigned by a leader, in draft order.
+-- offering_team_id is NULL for assigned players.
+CREATE TABLE IF NOT EXISTS draft_pick (
+  draft_id BIGINT NOT NULL,
+  pick_number INT NOT NULL,
+  player_id BIGINT NOT NULL,
+  salary INT NOT NULL,
+  offering_team_id BIGINT,
+  winning_team_id BIGINT NOT NULL,
+  start_time DATETIME(3) NOT NULL,
+  end_time DATETIME(3) NOT NULL,
+  PRIMARY KEY (draft_id, pick_number),
+  FOREIGN KEY (draft_id) REFERENCES draft (id),
+  FOREIGN KEY (player_id) REFERENCES player (id),
+  FOREIGN KEY (offering_team_id) REFERENCES team (id),
+  FOREIGN KEY (winning_team_id) REFERENCES team (id)
+);
+
+-- Append-only journal of everything that happened in a draft. See
+-- JournalEvent.
+CREATE TABLE IF NOT EXISTS draft_event (
+  draft_id BIGINT NOT NULL,
+  seq BIGINT NOT NULL,
+  event_time DATETIME(3) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  type VARCHAR(64) NOT NULL,
+  data TEXT,
+  PRIMARY KEY (draft_id, seq),
+  FOREIGN KEY (draft_id) REFERENCES draft (id)
+);
+
+-- Players nominated on a team's behalf when its nomination clock runs out,
+-- best first.
+CREATE TABLE IF NOT EXISTS draft_player_rank (
+  draft_id BIGINT NOT NULL,
+  ranking INT NOT NULL,
+  player_id BIGINT NOT NULL,
+  PRIMARY KEY (draft_id, ranking),
+  FOREIGN KEY (draft_id) REFERENCES draft (id),
+  FOREIGN KEY (player_id) REFERENCES player (id)
+);
+
+-- Players a team wants to nominate, in ascending position.
+CREATE TABLE IF NOT EXISTS team_queue (
+  team_id BIGINT NOT NULL,
+  position INT NOT NULL,
+  player_id BIGINT NOT NULL,
+  bid INT NOT NULL,
+  PRIMARY KEY (team_id, position),
+  FOREIGN KEY (team_id) REFERENCES team (id),
+  FOREIGN KEY (player_id) REFERENCES player (id)
+);
@@ -0,0 +1,143 @@
+package tnpldraft
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Orders search results can be sorted in.
+const (
+	SortByName    = "name"    // last name, then first name
+	SortByMlbteam = "mlbteam" // MLB team, then name
+)
+
+// The part of a player search answered by the PlayerCatalog.
+type PlayerQuery struct {
+	Name    string // substring of the player's full name
+	Mlbteam string // exact MLB team name; empty for any team
+	Sort    string // SortByName if empty
+	After   int64  // the last player on the previous page; 0 for the first page
+}
+
+// A player search within a draft.
+type PlayerSearch struct {
+	PlayerQuery
+	Position  string // a base position or slot the player is eligible for
+	Available bool   // exclude players already on a team
+	Fits      bool   // only players that fit the roster of Email's team
+	Email     string // who is searching
+	Limit     int
+}
+
+// A player in search results along with the team that owns them, if any.
+type PlayerResult struct {
+	*Player
+	Owner TeamId `json:"owner,omitempty"`
+}
+
+// A page of search results. Pass Next as the cursor to get the next page;
+// it's empty on the last page.
+type PlayerSearchResults struct {
+	Players []*PlayerResult `json:"players"`
+	Next    string          `json:"next,omitempty"`
+}
+
+// Parses a cursor returned in PlayerSearchResults.Next.
+func ParseCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	after, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || after <= 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return after, nil
+}
+
+// What a search needs to know about a draft, copied out of its
+// controller.
+type searchView struct {
+	slotRules SlotRules
+	slots     []string
+	owners    map[int64]TeamId
+	roster    []*Player // of the searcher's team; nil if they don't own one
+}
+
+func (c *DraftController) searchView(email string) *searchView {
+	view := &searchView{
+		slotRules: c.SlotRules,
+		slots:     c.slots,
+		owners:    make(map[int64]TeamId, len(c.playerOwners)),
+	}
+	for playerId, team := range c.playerOwners {
+		view.owners[playerId] = team.Id
+	}
+	if team, ok := c.owners[email]; ok {
+		view.roster = make([]*Player, len(team.Players))
+		for i, player := range team.Players {
+			view.roster[i] = player.Player
+		}
+	}
+	return view
+}
+
+// Page size for searches that don't set a limit.
+const defaultSearchLimit = 50
+
+// SearchPlayers returns a page of the players in draftId's catalog that
+// match search. Returns ErrNotAllowed if search.Email may not view the
+// draft.
+func (supervisor *DraftSupervisor) SearchPlayers(draftId int64, search *PlayerSearch) (*PlayerSearchResults, error) {
+	if search.Limit <= 0 {
+		search.Limit = defaultSearchLimit
+	}
+	var view *searchView
+	if err := supervisor.inspect(draftId, func(c *DraftController) {
+		if c.canView(search.Email) {
+			view = c.searchView(search.Email)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if view == nil {
+		return nil, ErrNotAllowed
+	}
+	if search.Fits && view.roster == nil {
+		return nil, fmt.Errorf("%v doesn't own a team in draft %v", search.Email, draftId)
+	}
+	keep := func(player *Player) bool {
+		player.Positions = view.slotRules.Positions(player.BasePositions)
+		if search.Position != "" && !contains(player.Positions, search.Position) {
+			return false
+		}
+		if search.Available && view.owners[player.Id] != 0 {
+			return false
+		}
+		if search.Fits {
+			roster := append(view.roster[:len(view.roster):len(view.roster)], player)
+			if _, ok := assignSlots(roster, view.slots); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	// One extra player tells whether there's another page.
+	players, err := supervisor.store.QueryPlayers(&search.PlayerQuery, keep, search.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	results := &PlayerSearchResults{
+		Players: []*PlayerResult{},
+	}
+	if len(players) > search.Limit {
+		players = players[:search.Limit]
+		results.Next = strconv.FormatInt(players[len(players)-1].Id, 10)
+	}
+	for _, player := range players {
+		results.Players = append(results.Players, &PlayerResult{
+			Player: player,
+			Owner:  view.owners[player.Id],
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,98 @@
+package tnpldraft
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Returned when someone asks for a draft they may not watch.
+var ErrNotAllowed = errors.New("not allowed to view this draft")
+
+var stateNames = map[DraftState]string{
+	WAITING_FOR_TEAMS:     "waiting_for_teams",
+	WAITING_FOR_PICK:      "waiting_for_pick",
+	PICK_PENDING_APPROVAL: "pick_pending_approval",
+	AUCTION_IN_PROGRESS:   "auction_in_progress",
+	DRAFT_COMPLETE:        "draft_complete",
+	DRAFT_PAUSED:          "draft_paused",
+}
+
+func (s DraftState) String() string {
+	return stateNames[s]
+}
+
+// How much of the salary cap a team has spent and the most it can bid on
+// its next player.
+type TeamBudget struct {
+	Team   TeamId `json:"team"`
+	Spent  int    `json:"spent"`
+	Left   int    `json:"left"`
+	MaxBid int    `json:"max_bid"`
+}
+
+// The state of a draft for clients that poll it instead of connecting.
+// Nominating is the team whose turn it is to nominate and Auction the
+// player on the block, if any.
+type DraftSnapshot struct {
+	*DraftController
+	Started    bool          `json:"started"`
+	State      string        `json:"state"`
+	Nominating TeamId        `json:"nominating,omitempty"`
+	Auction    *Auction      `json:"auction,omitempty"`
+	Budgets    []*TeamBudget `json:"budgets"`
+}
+
+// Whether email owns a team in, leads or may spectate the draft.
+func (c *DraftController) canView(email string) bool {
+	_, ok := c.owners[email]
+	return ok || c.isLeader(email) || c.canSpectate(email)
+}
+
+func (c *DraftController) snapshot() *DraftSnapshot {
+	snapshot := &DraftSnapshot{
+		DraftController: c,
+		Started:         c.started,
+		State:           c.state.String(),
+		Budgets:         make([]*TeamBudget, len(c.Teams)),
+	}
+	if c.auction != nil && c.auction.offeringTeam != nil && c.state != DRAFT_COMPLETE {
+		snapshot.Nominating = c.auction.offeringTeam.Id
+	}
+	if c.state == AUCTION_IN_PROGRESS {
+		auction := c.GetAuctionMessage()
+		snapshot.Auction = &auction
+	}
+	for i, team := range c.Teams {
+		budget := &TeamBudget{
+			Team:   team.Id,
+			MaxBid: c.maxTeamCanBid(team),
+		}
+		for _, player := range team.Players {
+			budget.Spent += player.Salary
+		}
+		budget.Left = c.SalaryCap - budget.Spent
+		snapshot.Budgets[i] = budget
+	}
+	return snapshot
+}
+
+// Snapshot returns draftId's state, as seen by email, encoded as JSON. A
+// running draft is encoded inside its event loop so the snapshot is
+// consistent; otherwise it's read from the store. Returns ErrNotAllowed
+// if email doesn't own a team, lead or spectate the draft.
+func (supervisor *DraftSupervisor) Snapshot(draftId int64, email string) (json.RawMessage, error) {
+	var (
+		encoded []byte
+		err     error
+	)
+	if inspectErr := supervisor.inspect(draftId, func(c *DraftController) {
+		if !c.canView(email) {
+			err = ErrNotAllowed
+			return
+		}
+		encoded, err = json.Marshal(c.snapshot())
+	}); inspectErr != nil {
+		return nil, inspectErr
+	}
+	return encoded, err
+}
@@ -0,0 +1,61 @@
+package tnpldraft
+
+import "log"
+
+func (c *DraftController) canSpectate(email string) bool {
+	if !c.Settings.AllowSpectators {
+		return false
+	}
+	if len(c.spectators) == 0 {
+		return true
+	}
+	for _, spectator := range c.spectators {
+		if spectator == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the channel messages for conn are sent on, or nil if conn isn't
+// registered.
+func (c *DraftController) connectionChannel(conn Connection) chan<- *SocketMessage {
+	if team, ok := c.owners[conn.User.Email]; ok {
+		return team.connections[conn]
+	}
+	return c.observers[conn]
+}
+
+// Forgets conn. Returns the team conn belonged to, or nil if it didn't
+// belong to a team.
+func (c *DraftController) removeConnection(conn Connection) *Team {
+	if team, ok := c.owners[conn.User.Email]; ok {
+		delete(team.connections, conn)
+		log.Printf("Unregistering connection %v for team %v", conn, team.Name)
+		return team
+	}
+	delete(c.observers, conn)
+	log.Printf("Unregistering spectator connection %v", conn)
+	return nil
+}
+
+// Rejects a message that requires a team from a spectator or commissioner.
+func (c *DraftController) rejectTeamless(msg *TeamMessage) {
+	c.reply(nil, msg, SocketMessageFrom(CommandRejected{
+		Command: msg.SocketMessage.Type,
+		Reason:  "You don't own a team in this draft",
+	}))
+}
+
+// Closes the connections of spectators who are no longer allowed to
+// watch.
+func (c *DraftController) disconnectSpectators() {
+	for conn, ch := range c.observers {
+		if c.isLeader(conn.User.Email) || c.canSpectate(conn.User.Email) {
+			continue
+		}
+		log.Printf("Disconnecting spectator %v", conn)
+		delete(c.observers, conn)
+		close(ch)
+	}
+}
@@ -0,0 +1,817 @@
+package tnpldraft
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PlayerCatalog looks up players in the database of MLB players. It's the
+// only source of player names and positions the server trusts.
+type PlayerCatalog interface {
+	// QueryPlayers returns up to limit players matching query, in the
+	// order query asks for, for which keep returns true. keep is called
+	// on each matching player in order and may fill in their Positions.
+	QueryPlayers(query *PlayerQuery, keep func(*Player) bool, limit int) ([]*Player, error)
+
+	// LoadPlayer returns the player with playerId. Only the player's
+	// BasePositions are filled in.
+	LoadPlayer(playerId int64) (*Player, error)
+}
+
+// DraftStore loads drafts from persistent storage.
+type DraftStore interface {
+	PlayerCatalog
+
+	// LoadDraft returns the configuration, teams and existing rosters for
+	// draftId. The returned controller has not been prepared to run; use
+	// NewController to get a runnable controller.
+	LoadDraft(draftId int64) (*DraftController, error)
+
+	// RecordAuction durably stores a completed auction for draftId.
+	RecordAuction(draftId int64, auction *AuctionComplete) error
+
+	// DeleteAuction removes the pick numbered pickNumber from draftId.
+	DeleteAuction(draftId int64, pickNumber int) error
+
+	// AppendEvent adds event to the end of draftId's journal.
+	AppendEvent(draftId int64, event *JournalEvent) error
+
+	// LoadEvents returns draftId's journal ordered by sequence number.
+	LoadEvents(draftId int64) ([]*JournalEvent, error)
+
+	// SaveQueue replaces the nomination queue of teamId.
+	SaveQueue(teamId TeamId, entries []*QueueEntry) error
+
+	// SaveSpectators replaces the people allowed to spectate draftId.
+	SaveSpectators(draftId int64, emails []string) error
+
+	// SaveSettings stores the settings leaders changed for draftId.
+	SaveSettings(draftId int64, settings *DraftSettings) error
+
+	// SaveKeeper adds keeper to the pre-draft roster of teamId.
+	SaveKeeper(teamId TeamId, keeper *OwnedPlayer) error
+
+	// DeleteKeeper removes playerId from the pre-draft roster of teamId.
+	DeleteKeeper(teamId TeamId, playerId int64) error
+
+	// CreateDraft stores a new draft configured by config and returns its
+	// id.
+	CreateDraft(config *DraftConfig) (int64, error)
+
+	// UpdateDraft replaces the configuration of draftId.
+	UpdateDraft(draftId int64, config *DraftConfig) error
+
+	// DeleteDraft removes draftId along with its teams, picks and journal.
+	DeleteDraft(draftId int64) error
+
+	// CreateTeam adds a team to draftId and returns its id.
+	CreateTeam(draftId int64, config *TeamConfig) (TeamId, error)
+
+	// UpdateTeam renames teamId and moves it to config.Order, unless it's 0.
+	UpdateTeam(teamId TeamId, config *TeamConfig) error
+
+	// DeleteTeam removes teamId along with its owners, keepers and queue.
+	DeleteTeam(teamId TeamId) error
+
+	// AddOwner and RemoveOwner change who owns teamId.
+	AddOwner(teamId TeamId, email string) error
+	RemoveOwner(teamId TeamId, email string) error
+
+	// AddLeader and RemoveLeader change who leads draftId.
+	AddLeader(draftId int64, email string) error
+	RemoveLeader(draftId int64, email string) error
+}
+
+// MySQLStore is a DraftStore backed by the tables described in schema.sql.
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// Create a new MySQLStore using db.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+// Columns selected for a player. Must be kept in sync with scanPlayer.
+const playerColumns = "player.id, player.firstname, player.lastname, player.pitcher, player.catcher, player.firstbase, player.secondbase, player.thirdbase, player.shortstop, player.outfield, mlbteam.name, (SELECT GROUP_CONCAT(position ORDER BY position SEPARATOR '|') FROM player_position WHERE player_position.player_id = player.id)"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scans a player selected with playerColumns, followed by extra. Only the
+// player's base positions are filled in; the slots they make the player
+// eligible for depend on the draft's SlotRules.
+func scanPlayer(row rowScanner, extra ...interface{}) (*Player, error) {
+	var (
+		id                                      int64
+		firstname, lastname, team               string
+		pitcher, catcher, firstbase, secondbase bool
+		thirdbase, shortstop, outfield          bool
+		otherPositions                          sql.NullString
+	)
+	dest := []interface{}{&id, &firstname, &lastname, &pitcher, &catcher, &firstbase, &secondbase, &thirdbase, &shortstop, &outfield, &team, &otherPositions}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	player := Player{
+		Id:            id,
+		Firstname:     firstname,
+		Lastname:      lastname,
+		Mlbteam:       team,
+		BasePositions: []string{},
+	}
+	for _, base := range []struct {
+		eligible bool
+		pos      string
+	}{
+		{pitcher, "P"},
+		{catcher, "C"},
+		{firstbase, "1B"},
+		{secondbase, "2B"},
+		{thirdbase, "3B"},
+		{shortstop, "SS"},
+		{outfield, "OF"},
+	} {
+		if base.eligible {
+			player.BasePositions = append(player.BasePositions, base.pos)
+		}
+	}
+	if otherPositions.Valid {
+		for _, pos := range strings.Split(otherPositions.String, "|") {
+			if !contains(player.BasePositions, pos) {
+				player.BasePositions = append(player.BasePositions, pos)
+			}
+		}
+	}
+	return &player, nil
+}
+
+// ORDER BY clauses for each way players can be sorted, and the matching
+// columns to compare against the previous page's last player.
+var playerSorts = map[string]struct{ order, key string }{
+	SortByName:    {"player.lastname, player.firstname, player.id", "(player.lastname, player.firstname, player.id)"},
+	SortByMlbteam: {"mlbteam.name, player.lastname, player.firstname, player.id", "(mlbteam.name, player.lastname, player.firstname, player.id)"},
+}
+
+func (s *MySQLStore) QueryPlayers(query *PlayerQuery, keep func(*Player) bool, limit int) ([]*Player, error) {
+	sortBy := query.Sort
+	if sortBy == "" {
+		sortBy = SortByName
+	}
+	columns, ok := playerSorts[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("can't sort players by %q", sortBy)
+	}
+	from := " FROM player JOIN mlbteam ON player.mlbteam_id = mlbteam.id"
+	stmt := "SELECT " + playerColumns + from + " WHERE CONCAT(player.firstname, ' ', player.lastname) LIKE CONCAT('%', ?, '%')"
+	args := []interface{}{query.Name}
+	if query.Mlbteam != "" {
+		stmt += " AND mlbteam.name = ?"
+		args = append(args, query.Mlbteam)
+	}
+	if query.After != 0 {
+		stmt += " AND " + columns.key + " > (SELECT " + strings.Trim(columns.key, "()") + from + " WHERE player.id = ?)"
+		args = append(args, query.After)
+	}
+	rows, err := s.db.Query(stmt+" ORDER BY "+columns.order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	players := make([]*Player, 0)
+	for len(players) < limit && rows.Next() {
+		player, err := scanPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		if keep(player) {
+			players = append(players, player)
+		}
+	}
+	return players, rows.Err()
+}
+
+func (s *MySQLStore) LoadPlayer(playerId int64) (*Player, error) {
+	row := s.db.QueryRow("SELECT "+playerColumns+" FROM player JOIN mlbteam ON player.mlbteam_id = mlbteam.id WHERE player.id = ?", playerId)
+	player, err := scanPlayer(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no player with id %v", playerId)
+	}
+	return player, err
+}
+
+func (s *MySQLStore) LoadDraft(draftId int64) (*DraftController, error) {
+	conf := DraftController{
+		id:                draftId,
+		Teams:             []*Team{},
+		leaders:           []string{},
+		CompletedAuctions: []*AuctionComplete{},
+		RequiredPos:       map[string]int{},
+		RuleConfigs:       []*RuleConfig{},
+	}
+	settings := &conf.Settings
+	err := s.db.QueryRow("SELECT name, salary_cap, min_salary, max_salary, require_approval, auction_seconds, bid_extension_seconds, extension_threshold_seconds, nomination_seconds, nomination_timeout, allow_spectators FROM draft WHERE id = ?", draftId).Scan(
+		&conf.Name, &conf.SalaryCap, &conf.MinSalary, &conf.MaxSalary, &settings.RequireApproval, &settings.AuctionSeconds, &settings.BidExtensionSeconds, &settings.ExtensionThresholdSeconds, &settings.NominationSeconds, &settings.NominationTimeout, &settings.AllowSpectators)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no draft with id %v", draftId)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadBidIncrements(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadRequiredPositions(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadRules(&conf); err != nil {
+		return nil, err
+	}
+	if conf.SlotRules, err = s.loadSlotRules(draftId); err != nil {
+		return nil, err
+	}
+	if err := s.loadLeaders(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadSpectators(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadTeams(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadOwners(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadRosters(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadPicks(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadRankedPool(&conf); err != nil {
+		return nil, err
+	}
+	if err := s.loadQueues(&conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+func (s *MySQLStore) SaveSettings(draftId int64, settings *DraftSettings) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE draft SET require_approval = ?, auction_seconds = ?, bid_extension_seconds = ?, extension_threshold_seconds = ?, nomination_seconds = ?, nomination_timeout = ?, allow_spectators = ? WHERE id = ?",
+		settings.RequireApproval, settings.AuctionSeconds, settings.BidExtensionSeconds, settings.ExtensionThresholdSeconds, settings.NominationSeconds, settings.NominationTimeout, settings.AllowSpectators, draftId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := saveBidIncrements(tx, draftId, settings.BidIncrements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) loadBidIncrements(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT min_bid, increment FROM draft_bid_increment WHERE draft_id = ? ORDER BY min_bid", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tier BidIncrement
+		if err := rows.Scan(&tier.From, &tier.Increment); err != nil {
+			return err
+		}
+		conf.Settings.BidIncrements = append(conf.Settings.BidIncrements, tier)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) loadRequiredPositions(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT position, count FROM draft_position WHERE draft_id = ?", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			pos   string
+			count int
+		)
+		if err := rows.Scan(&pos, &count); err != nil {
+			return err
+		}
+		conf.RequiredPos[pos] = count
+	}
+	return rows.Err()
+}
+
+// Returns the slot rules of draftId, or DefaultSlotRules if it has none.
+func (s *MySQLStore) loadSlotRules(draftId int64) (SlotRules, error) {
+	rows, err := s.db.Query("SELECT slot, eligible FROM draft_slot WHERE draft_id = ?", draftId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := SlotRules{}
+	for rows.Next() {
+		var (
+			slot string
+			rule SlotRule
+		)
+		if err := rows.Scan(&slot, &rule); err != nil {
+			return nil, err
+		}
+		rules[slot] = rule
+	}
+	if len(rules) == 0 {
+		rules = DefaultSlotRules
+	}
+	return rules, rows.Err()
+}
+
+func (s *MySQLStore) loadRules(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT kind, rule_limit, rule_positions FROM draft_rule WHERE draft_id = ? ORDER BY kind", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var config RuleConfig
+		if err := rows.Scan(&config.Kind, &config.Limit, &config.Positions); err != nil {
+			return err
+		}
+		conf.RuleConfigs = append(conf.RuleConfigs, &config)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) loadLeaders(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT email FROM draft_leader WHERE draft_id = ?", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return err
+		}
+		conf.leaders = append(conf.leaders, email)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) loadSpectators(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT email FROM draft_spectator WHERE draft_id = ?", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	conf.spectators = []string{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return err
+		}
+		conf.spectators = append(conf.spectators, email)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) SaveSpectators(draftId int64, emails []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM draft_spectator WHERE draft_id = ?", draftId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, email := range emails {
+		if _, err := tx.Exec("INSERT INTO draft_spectator (draft_id, email) VALUES (?, ?)", draftId, email); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) loadTeams(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT id, name FROM team WHERE draft_id = ? ORDER BY draft_order", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		team := Team{
+			Players:     []*OwnedPlayer{},
+			connections: make(map[Connection]chan<- *SocketMessage),
+			owners:      []string{},
+		}
+		if err := rows.Scan(&team.Id, &team.Name); err != nil {
+			return err
+		}
+		conf.Teams = append(conf.Teams, &team)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) loadOwners(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT team_owner.team_id, team_owner.email FROM team_owner JOIN team ON team_owner.team_id = team.id WHERE team.draft_id = ?", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			teamId TeamId
+			email  string
+		)
+		if err := rows.Scan(&teamId, &email); err != nil {
+			return err
+		}
+		team := conf.teamById(teamId)
+		if team == nil {
+			return fmt.Errorf("owner %v references unknown team %v", email, teamId)
+		}
+		team.owners = append(team.owners, email)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) loadRosters(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT "+playerColumns+", roster.team_id, roster.salary FROM roster JOIN team ON roster.team_id = team.id JOIN player ON roster.player_id = player.id JOIN mlbteam ON player.mlbteam_id = mlbteam.id WHERE team.draft_id = ? ORDER BY roster.id", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			teamId TeamId
+			salary int
+		)
+		player, err := scanPlayer(rows, &teamId, &salary)
+		if err != nil {
+			return err
+		}
+		team := conf.teamById(teamId)
+		if team == nil {
+			return fmt.Errorf("roster entry for player %v references unknown team %v", player.Id, teamId)
+		}
+		team.Players = append(team.Players, &OwnedPlayer{
+			Player: player,
+			Salary: salary,
+			Keeper: true,
+		})
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) loadPicks(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT "+playerColumns+", draft_pick.pick_number, draft_pick.salary, COALESCE(draft_pick.offering_team_id, 0), draft_pick.winning_team_id, draft_pick.start_time, draft_pick.end_time FROM draft_pick JOIN player ON draft_pick.player_id = player.id JOIN mlbteam ON player.mlbteam_id = mlbteam.id WHERE draft_pick.draft_id = ? ORDER BY draft_pick.pick_number", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			auction AuctionComplete
+			salary  int
+		)
+		player, err := scanPlayer(rows, &auction.PickNumber, &salary, &auction.OfferingTeam, &auction.WinningTeam, &auction.StartTime, &auction.EndTime)
+		if err != nil {
+			return err
+		}
+		team := conf.teamById(auction.WinningTeam)
+		if team == nil {
+			return fmt.Errorf("pick %v references unknown team %v", auction.PickNumber, auction.WinningTeam)
+		}
+		auction.Player = &OwnedPlayer{
+			Player: player,
+			Salary: salary,
+		}
+		team.Players = append(team.Players, auction.Player)
+		conf.CompletedAuctions = append(conf.CompletedAuctions, &auction)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) RecordAuction(draftId int64, auction *AuctionComplete) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Lock the draft so concurrent writers can't interleave pick numbers.
+	var lastPick int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(pick_number), 0) FROM draft_pick WHERE draft_id = ? FOR UPDATE", draftId).Scan(&lastPick); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if lastPick+1 != auction.PickNumber {
+		tx.Rollback()
+		return fmt.Errorf("pick %v is out of order; last recorded pick is %v", auction.PickNumber, lastPick)
+	}
+	// Players assigned by a leader have no offering team.
+	offeringTeam := sql.NullInt64{
+		Int64: int64(auction.OfferingTeam),
+		Valid: auction.OfferingTeam != 0,
+	}
+	_, err = tx.Exec("INSERT INTO draft_pick (draft_id, pick_number, player_id, salary, offering_team_id, winning_team_id, start_time, end_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		draftId, auction.PickNumber, auction.Player.Id, auction.Player.Salary, offeringTeam, auction.WinningTeam, auction.StartTime, auction.EndTime)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) DeleteAuction(draftId int64, pickNumber int) error {
+	_, err := s.db.Exec("DELETE FROM draft_pick WHERE draft_id = ? AND pick_number = ?", draftId, pickNumber)
+	return err
+}
+
+func (s *MySQLStore) AppendEvent(draftId int64, event *JournalEvent) error {
+	_, err := s.db.Exec("INSERT INTO draft_event (draft_id, seq, event_time, email, type, data) VALUES (?, ?, ?, ?, ?, ?)",
+		draftId, event.Seq, event.Time, event.Email, event.Type, []byte(event.Data))
+	return err
+}
+
+func (s *MySQLStore) LoadEvents(draftId int64) ([]*JournalEvent, error) {
+	rows, err := s.db.Query("SELECT seq, event_time, email, type, data FROM draft_event WHERE draft_id = ? ORDER BY seq", draftId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*JournalEvent{}
+	for rows.Next() {
+		var (
+			event JournalEvent
+			data  []byte
+		)
+		if err := rows.Scan(&event.Seq, &event.Time, &event.Email, &event.Type, &data); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			event.Data = json.RawMessage(data)
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (s *MySQLStore) loadRankedPool(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT "+playerColumns+" FROM draft_player_rank JOIN player ON draft_player_rank.player_id = player.id JOIN mlbteam ON player.mlbteam_id = mlbteam.id WHERE draft_player_rank.draft_id = ? ORDER BY draft_player_rank.ranking", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		player, err := scanPlayer(rows)
+		if err != nil {
+			return err
+		}
+		conf.rankedPool = append(conf.rankedPool, player)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) loadQueues(conf *DraftController) error {
+	rows, err := s.db.Query("SELECT "+playerColumns+", team_queue.team_id, team_queue.bid FROM team_queue JOIN team ON team_queue.team_id = team.id JOIN player ON team_queue.player_id = player.id JOIN mlbteam ON player.mlbteam_id = mlbteam.id WHERE team.draft_id = ? ORDER BY team_queue.position", conf.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			teamId TeamId
+			entry  QueueEntry
+		)
+		player, err := scanPlayer(rows, &teamId, &entry.Bid)
+		if err != nil {
+			return err
+		}
+		team := conf.teamById(teamId)
+		if team == nil {
+			return fmt.Errorf("queue entry for player %v references unknown team %v", player.Id, teamId)
+		}
+		entry.Player = player
+		team.queue = append(team.queue, &entry)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) SaveQueue(teamId TeamId, entries []*QueueEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM team_queue WHERE team_id = ?", teamId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i, entry := range entries {
+		if _, err := tx.Exec("INSERT INTO team_queue (team_id, position, player_id, bid) VALUES (?, ?, ?, ?)", teamId, i, entry.Player.Id, entry.Bid); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) SaveKeeper(teamId TeamId, keeper *OwnedPlayer) error {
+	_, err := s.db.Exec("INSERT INTO roster (team_id, player_id, salary) VALUES (?, ?, ?)", teamId, keeper.Id, keeper.Salary)
+	return err
+}
+
+func (s *MySQLStore) DeleteKeeper(teamId TeamId, playerId int64) error {
+	_, err := s.db.Exec("DELETE FROM roster WHERE team_id = ? AND player_id = ?", teamId, playerId)
+	return err
+}
+
+// Replaces the required positions of draftId within tx.
+func saveRequiredPositions(tx *sql.Tx, draftId int64, required map[string]int) error {
+	if _, err := tx.Exec("DELETE FROM draft_position WHERE draft_id = ?", draftId); err != nil {
+		return err
+	}
+	for pos, count := range required {
+		if _, err := tx.Exec("INSERT INTO draft_position (draft_id, position, count) VALUES (?, ?, ?)", draftId, pos, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replaces the bid increments of draftId within tx.
+func saveBidIncrements(tx *sql.Tx, draftId int64, increments []BidIncrement) error {
+	if _, err := tx.Exec("DELETE FROM draft_bid_increment WHERE draft_id = ?", draftId); err != nil {
+		return err
+	}
+	for _, tier := range increments {
+		if _, err := tx.Exec("INSERT INTO draft_bid_increment (draft_id, min_bid, increment) VALUES (?, ?, ?)", draftId, tier.From, tier.Increment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MySQLStore) CreateDraft(config *DraftConfig) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	settings := config.Settings
+	result, err := tx.Exec("INSERT INTO draft (name, salary_cap, min_salary, max_salary, require_approval, auction_seconds, bid_extension_seconds, extension_threshold_seconds, nomination_seconds, nomination_timeout, allow_spectators) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		config.Name, config.SalaryCap, config.MinSalary, config.MaxSalary, settings.RequireApproval, settings.AuctionSeconds, settings.BidExtensionSeconds, settings.ExtensionThresholdSeconds, settings.NominationSeconds, settings.NominationTimeout, settings.AllowSpectators)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	draftId, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := saveRequiredPositions(tx, draftId, config.RequiredPos); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := saveBidIncrements(tx, draftId, settings.BidIncrements); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return draftId, tx.Commit()
+}
+
+func (s *MySQLStore) UpdateDraft(draftId int64, config *DraftConfig) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	settings := config.Settings
+	_, err = tx.Exec("UPDATE draft SET name = ?, salary_cap = ?, min_salary = ?, max_salary = ?, require_approval = ?, auction_seconds = ?, bid_extension_seconds = ?, extension_threshold_seconds = ?, nomination_seconds = ?, nomination_timeout = ?, allow_spectators = ? WHERE id = ?",
+		config.Name, config.SalaryCap, config.MinSalary, config.MaxSalary, settings.RequireApproval, settings.AuctionSeconds, settings.BidExtensionSeconds, settings.ExtensionThresholdSeconds, settings.NominationSeconds, settings.NominationTimeout, settings.AllowSpectators, draftId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := saveRequiredPositions(tx, draftId, config.RequiredPos); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := saveBidIncrements(tx, draftId, settings.BidIncrements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) DeleteDraft(draftId int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Children before parents, to satisfy the foreign keys.
+	for _, stmt := range []string{
+		"DELETE FROM draft_event WHERE draft_id = ?",
+		"DELETE FROM draft_pick WHERE draft_id = ?",
+		"DELETE team_queue FROM team_queue JOIN team ON team_queue.team_id = team.id WHERE team.draft_id = ?",
+		"DELETE roster FROM roster JOIN team ON roster.team_id = team.id WHERE team.draft_id = ?",
+		"DELETE team_owner FROM team_owner JOIN team ON team_owner.team_id = team.id WHERE team.draft_id = ?",
+		"DELETE FROM team WHERE draft_id = ?",
+		"DELETE FROM draft_player_rank WHERE draft_id = ?",
+		"DELETE FROM draft_spectator WHERE draft_id = ?",
+		"DELETE FROM draft_leader WHERE draft_id = ?",
+		"DELETE FROM draft_position WHERE draft_id = ?",
+		"DELETE FROM draft_slot WHERE draft_id = ?",
+		"DELETE FROM draft_rule WHERE draft_id = ?",
+		"DELETE FROM draft_bid_increment WHERE draft_id = ?",
+		"DELETE FROM draft WHERE id = ?",
+	} {
+		if _, err := tx.Exec(stmt, draftId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) CreateTeam(draftId int64, config *TeamConfig) (TeamId, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	order := config.Order
+	if order == 0 {
+		if err := tx.QueryRow("SELECT COALESCE(MAX(draft_order), 0) + 1 FROM team WHERE draft_id = ? FOR UPDATE", draftId).Scan(&order); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	result, err := tx.Exec("INSERT INTO team (draft_id, name, draft_order) VALUES (?, ?, ?)", draftId, config.Name, order)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	teamId, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return TeamId(teamId), tx.Commit()
+}
+
+func (s *MySQLStore) UpdateTeam(teamId TeamId, config *TeamConfig) error {
+	if config.Order == 0 {
+		_, err := s.db.Exec("UPDATE team SET name = ? WHERE id = ?", config.Name, teamId)
+		return err
+	}
+	_, err := s.db.Exec("UPDATE team SET name = ?, draft_order = ? WHERE id = ?", config.Name, config.Order, teamId)
+	return err
+}
+
+func (s *MySQLStore) DeleteTeam(teamId TeamId) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"DELETE FROM team_queue WHERE team_id = ?",
+		"DELETE FROM roster WHERE team_id = ?",
+		"DELETE FROM team_owner WHERE team_id = ?",
+		"DELETE FROM team WHERE id = ?",
+	} {
+		if _, err := tx.Exec(stmt, teamId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) AddOwner(teamId TeamId, email string) error {
+	_, err := s.db.Exec("INSERT INTO team_owner (team_id, email) VALUES (?, ?)", teamId, email)
+	return err
+}
+
+func (s *MySQLStore) RemoveOwner(teamId TeamId, email string) error {
+	_, err := s.db.Exec("DELETE FROM team_owner WHERE team_id = ? AND email = ?", teamId, email)
+	return err
+}
+
+func (s *MySQLStore) AddLeader(draftId int64, email string) error {
+	_, err := s.db.Exec("INSERT INTO draft_leader (draft_id, email) VALUES (?, ?)", draftId, email)
+	return err
+}
+
+func (s *MySQLStore) RemoveLeader(draftId int64, email string) error {
+	_, err := s.db.Exec("DELETE FROM draft_leader WHERE draft_id = ? AND email = ?", draftId, email)
+	return err
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/ggriffiniii/googleauth"
+	"github.com/ggriffiniii/tnpldraft"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var admins = flag.String("admins", "", "Comma separated emails of the people who may create and configure drafts")
+
+type authenticator interface {
+	ProtectedHandler(h http.Handler) http.Handler
+	GetProfile(r *http.Request) (*googleauth.Profile, error)
+}
+
+// Wraps handler so only admins may call it.
+func adminOnly(auth authenticator, handler http.HandlerFunc) http.Handler {
+	return auth.ProtectedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profile, err := auth.GetProfile(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		for _, admin := range strings.Split(*admins, ",") {
+			if admin != "" && strings.TrimSpace(admin) == profile.Email {
+				handler(w, r)
+				return
+			}
+		}
+		http.Error(w, profile.Email+" is not an admin", 403)
+	}))
+}
+
+// Writes an error returned by the supervisor with a matching status.
+func adminError(w http.ResponseWriter, err error) {
+	switch err {
+	case tnpldraft.ErrDraftStarted, tnpldraft.ErrDraftRunning:
+		http.Error(w, err.Error(), 409)
+	default:
+		http.Error(w, err.Error(), 400)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+// Parses the draft and, if the route has one, team ids from r.
+func adminIds(w http.ResponseWriter, r *http.Request) (int64, tnpldraft.TeamId, bool) {
+	vars := mux.Vars(r)
+	draftId, err := strconv.ParseInt(vars["draftId"], 10, 64)
+	if err != nil {
+		http.Error(w, "draftid needs to be a number", 400)
+		return 0, 0, false
+	}
+	if _, ok := vars["teamId"]; !ok {
+		return draftId, 0, true
+	}
+	teamId, err := strconv.ParseInt(vars["teamId"], 10, 64)
+	if err != nil {
+		http.Error(w, "teamid needs to be a number", 400)
+		return 0, 0, false
+	}
+	return draftId, tnpldraft.TeamId(teamId), true
+}
+
+// Adds the endpoints admins use to create drafts, teams, owners and
+// leaders. Teams, roster requirements and salaries can't be changed once
+// a draft has started; names, settings, owners and leaders can.
+func addAdminRoutes(r *mux.Router, auth authenticator, supervisor *tnpldraft.DraftSupervisor) {
+	r.Handle("/api/admin/draft", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		var config tnpldraft.DraftConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		draftId, err := supervisor.CreateDraft(&config)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		writeJSON(w, map[string]int64{"id": draftId})
+	})).Methods("POST")
+	r.Handle("/api/admin/draft/{draftId}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, _, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		view, err := supervisor.DraftConfig(draftId)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, view)
+	})).Methods("GET")
+	r.Handle("/api/admin/draft/{draftId}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, _, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		var config tnpldraft.DraftConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := supervisor.UpdateDraft(draftId, &config); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("PUT")
+	r.Handle("/api/admin/draft/{draftId}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, _, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		if err := supervisor.DeleteDraft(draftId); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("DELETE")
+	r.Handle("/api/admin/draft/{draftId}/team", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, _, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		var config tnpldraft.TeamConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		teamId, err := supervisor.AddTeam(draftId, &config)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		writeJSON(w, map[string]tnpldraft.TeamId{"id": teamId})
+	})).Methods("POST")
+	r.Handle("/api/admin/draft/{draftId}/team/{teamId}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, teamId, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		var config tnpldraft.TeamConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := supervisor.UpdateTeam(draftId, teamId, &config); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("PUT")
+	r.Handle("/api/admin/draft/{draftId}/team/{teamId}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, teamId, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		if err := supervisor.DeleteTeam(draftId, teamId); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("DELETE")
+	r.Handle("/api/admin/draft/{draftId}/team/{teamId}/owner/{email}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, teamId, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		if err := supervisor.AddOwner(draftId, teamId, mux.Vars(r)["email"]); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("PUT")
+	r.Handle("/api/admin/draft/{draftId}/team/{teamId}/owner/{email}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, teamId, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		if err := supervisor.RemoveOwner(draftId, teamId, mux.Vars(r)["email"]); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("DELETE")
+	r.Handle("/api/admin/draft/{draftId}/leader/{email}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, _, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		if err := supervisor.AddLeader(draftId, mux.Vars(r)["email"]); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("PUT")
+	r.Handle("/api/admin/draft/{draftId}/leader/{email}", adminOnly(auth, func(w http.ResponseWriter, r *http.Request) {
+		draftId, _, ok := adminIds(w, r)
+		if !ok {
+			return
+		}
+		if err := supervisor.RemoveLeader(draftId, mux.Vars(r)["email"]); err != nil {
+			adminError(w, err)
+		}
+	})).Methods("DELETE")
+}
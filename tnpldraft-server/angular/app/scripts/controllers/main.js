@@ -53,7 +53,7 @@ angular.module('tnpldraftApp')
 			draftSocket.send(angular.toJson({
 				type: 'Pick',
 				data: {
-					player: player,
+					player_id: player.id,
 					bid: bid
 				}
 			}));
@@ -63,7 +63,7 @@ angular.module('tnpldraftApp')
 			draftSocket.send(angular.toJson({
 				type: 'Bid',
 				data: {
-					player: player,
+					player_id: player.id,
 					bid: bid
 				}
 			}));
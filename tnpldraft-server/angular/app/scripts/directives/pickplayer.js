@@ -29,10 +29,11 @@ angular.module('tnpldraftApp')
 				scope.getPlayers = function(search) {
 					return $http.get('/api/draft/5/playerfilter', {
 						params: {
-							name: search
+							name: search,
+							available: true
 						}
 					}).then(function(res) {
-						return res.data.map(function(playerInfo) {
+						return res.data.players.map(function(playerInfo) {
 							return draftState.newPlayer(playerInfo);
 						});
 					});
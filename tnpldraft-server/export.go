@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/ggriffiniii/tnpldraft"
+	"net/http"
+	"os"
+)
+
+// Writes rows to w as format, either "csv" or "json". An empty format is
+// JSON.
+func writeExport(w http.ResponseWriter, rows []*tnpldraft.ExportRow, format string) error {
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=draft.csv")
+		return tnpldraft.WriteCSV(w, rows)
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(rows)
+	}
+	http.Error(w, fmt.Sprintf("unknown format %q", format), 400)
+	return nil
+}
+
+// Runs the export subcommand, which writes a draft's final rosters to
+// stdout:
+//
+//	tnpldraft-server export -draft 5 -format csv > draft.csv
+func runExport(store tnpldraft.DraftStore, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	draftId := flags.Int64("draft", 0, "The id of the draft to export")
+	format := flags.String("format", "csv", "Either csv or json")
+	flags.Parse(args)
+	if *draftId == 0 {
+		return fmt.Errorf("export needs a -draft")
+	}
+	rows, err := tnpldraft.ExportDraft(store, *draftId)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "csv":
+		return tnpldraft.WriteCSV(os.Stdout, rows)
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 )
 
@@ -29,11 +30,18 @@ var dbpass = flag.String("dbpass", "tnpldraft", "The database password to use")
 func main() {
 	flag.Parse()
 	auth := googleauth.New(*clientId, *clientSecret, *cookieName, *oauthURL)
-	db, err := sql.Open("mysql", fmt.Sprintf("%v:%v@/%v", *dbuser, *dbpass, *db))
+	db, err := sql.Open("mysql", fmt.Sprintf("%v:%v@/%v?parseTime=true", *dbuser, *dbpass, *db))
 	if err != nil {
 		log.Fatal(err)
 	}
-	draftSupervisor := tnpldraft.NewSupervisor()
+	store := tnpldraft.NewMySQLStore(db)
+	if flag.Arg(0) == "export" {
+		if err := runExport(store, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	draftSupervisor := tnpldraft.NewSupervisor(store)
 	r := mux.NewRouter()
 	r.Handle("/oauthcallback", auth.OauthHandler())
 	r.Handle("/testauth", auth.ProtectedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -62,92 +70,182 @@ func main() {
 			http.Error(w, "draftid needs to be a number", 400)
 			return
 		}
-		err = draftSupervisor.RegisterConnection(draftId, conn)
+		var resume *tnpldraft.Resume
+		if since := r.URL.Query().Get("since"); since != "" {
+			seq, seqErr := strconv.ParseInt(since, 10, 64)
+			epoch, epochErr := strconv.ParseInt(r.URL.Query().Get("epoch"), 10, 64)
+			if seqErr == nil && epochErr == nil {
+				resume = &tnpldraft.Resume{
+					Epoch: epoch,
+					Seq:   seq,
+				}
+			}
+		}
+		err = draftSupervisor.RegisterConnection(draftId, conn, resume)
 		if err != nil {
 			http.Error(w, "Couldn't load draft", 500)
 			return
 		}
 	})))
+	r.Handle("/api/draft/{draftId}", auth.ProtectedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draftId, err := strconv.ParseInt(mux.Vars(r)["draftId"], 10, 64)
+		if err != nil {
+			http.Error(w, "draftid needs to be a number", 400)
+			return
+		}
+		profile, err := auth.GetProfile(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		snapshot, err := draftSupervisor.Snapshot(draftId, profile.Email)
+		if err == tnpldraft.ErrNotAllowed {
+			http.Error(w, err.Error(), 403)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(snapshot)
+	})))
 	r.Handle("/api/draft/{draftId}/playerfilter", auth.ProtectedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//		draftId, err := strconv.ParseInt(mux.Vars(r)["draftId"], 10, 64)
-		//		if err != nil {
-		//			http.Error(w, "draftid needs to be a number", 400)
-		//			return
-		//		}
-		params := r.URL.Query()
-		name := params.Get("name")
-		if err := getPlayers(w, name, db); err != nil {
+		draftId, err := strconv.ParseInt(mux.Vars(r)["draftId"], 10, 64)
+		if err != nil {
+			http.Error(w, "draftid needs to be a number", 400)
+			return
+		}
+		profile, err := auth.GetProfile(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		search, err := parsePlayerSearch(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		search.Email = profile.Email
+		results, err := draftSupervisor.SearchPlayers(draftId, search)
+		if err == tnpldraft.ErrNotAllowed {
+			http.Error(w, err.Error(), 403)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			log.Println(err)
+		}
 	})))
-	r.Handle("/{unused:.*}", auth.ProtectedHandler(http.FileServer(http.Dir(*static_dir))))
-	log.Println("Listening on ", *port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *port), r))
-}
-
-func getPlayers(w http.ResponseWriter, name string, db *sql.DB) error {
-	rows, err := db.Query("select player.id, player.firstname, player.lastname, player.pitcher, player.catcher, player.firstbase, player.secondbase, player.thirdbase, player.shortstop, player.outfield, player.utility, mlbteam.name FROM player JOIN mlbteam ON player.mlbteam_id = mlbteam.id WHERE CONCAT(player.firstname, ' ', player.lastname) LIKE CONCAT('%', ?, '%') LIMIT 50;", name)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-	players := make([]*tnpldraft.Player, 0)
-	for rows.Next() {
-		var (
-			id                                      int64
-			firstname, lastname, team               string
-			pitcher, catcher, firstbase, secondbase bool
-			thirdbase, shortstop, outfield, utility bool
-		)
-		err := rows.Scan(&id, &firstname, &lastname, &pitcher, &catcher, &firstbase, &secondbase, &thirdbase, &shortstop, &outfield, &utility, &team)
+	r.Handle("/api/draft/{draftId}/journal", auth.ProtectedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draftId, err := strconv.ParseInt(mux.Vars(r)["draftId"], 10, 64)
 		if err != nil {
-			return err
+			http.Error(w, "draftid needs to be a number", 400)
+			return
 		}
-		player := tnpldraft.Player{
-			Id:        id,
-			Firstname: firstname,
-			Lastname:  lastname,
-			Mlbteam:   team,
-			Positions: []string{},
+		profile, err := auth.GetProfile(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		events, err := draftSupervisor.Journal(draftId, profile.Email)
+		if err == tnpldraft.ErrNotAllowed {
+			http.Error(w, err.Error(), 403)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(events); err != nil {
+			log.Println(err)
 		}
-		if pitcher {
-			player.Positions = append(player.Positions, "P")
+	})))
+	r.Handle("/api/draft/{draftId}/replay/{seq}", auth.ProtectedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draftId, err := strconv.ParseInt(mux.Vars(r)["draftId"], 10, 64)
+		if err != nil {
+			http.Error(w, "draftid needs to be a number", 400)
+			return
 		}
-		if catcher {
-			player.Positions = append(player.Positions, "C")
+		seq, err := strconv.ParseInt(mux.Vars(r)["seq"], 10, 64)
+		if err != nil {
+			http.Error(w, "seq needs to be a number", 400)
+			return
 		}
-		if secondbase {
-			player.Positions = append(player.Positions, "2B")
+		profile, err := auth.GetProfile(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
-		if shortstop {
-			player.Positions = append(player.Positions, "SS")
+		snapshot, err := draftSupervisor.Replay(draftId, profile.Email, seq)
+		if err == tnpldraft.ErrNotAllowed {
+			http.Error(w, err.Error(), 403)
+			return
 		}
-		if secondbase || shortstop {
-			player.Positions = append(player.Positions, "MI")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
-		if thirdbase {
-			player.Positions = append(player.Positions, "3B")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(snapshot)
+	})))
+	r.Handle("/api/draft/{draftId}/export", auth.ProtectedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draftId, err := strconv.ParseInt(mux.Vars(r)["draftId"], 10, 64)
+		if err != nil {
+			http.Error(w, "draftid needs to be a number", 400)
+			return
 		}
-		if firstbase {
-			player.Positions = append(player.Positions, "1B")
+		profile, err := auth.GetProfile(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
-		if firstbase || thirdbase {
-			player.Positions = append(player.Positions, "CI")
+		rows, err := draftSupervisor.Export(draftId, profile.Email)
+		if err == tnpldraft.ErrNotAllowed {
+			http.Error(w, err.Error(), 403)
+			return
 		}
-		if outfield {
-			player.Positions = append(player.Positions, "OF")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
-		if !pitcher {
-			player.Positions = append(player.Positions, "U")
+		if err := writeExport(w, rows, r.URL.Query().Get("format")); err != nil {
+			log.Println(err)
 		}
+	})))
+	addAdminRoutes(r, auth, draftSupervisor)
+	r.Handle("/{unused:.*}", auth.ProtectedHandler(http.FileServer(http.Dir(*static_dir))))
+	log.Println("Listening on ", *port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *port), r))
+}
+
+// Most players a single search may return.
+const maxSearchLimit = 200
 
-		players = append(players, &player)
+func parsePlayerSearch(params url.Values) (*tnpldraft.PlayerSearch, error) {
+	search := &tnpldraft.PlayerSearch{
+		PlayerQuery: tnpldraft.PlayerQuery{
+			Name:    params.Get("name"),
+			Mlbteam: params.Get("mlbteam"),
+			Sort:    params.Get("sort"),
+		},
+		Position:  params.Get("position"),
+		Available: params.Get("available") == "true",
+		Fits:      params.Get("fits") == "true",
 	}
-	if err := rows.Err(); err != nil {
-		return err
+	after, err := tnpldraft.ParseCursor(params.Get("cursor"))
+	if err != nil {
+		return nil, err
+	}
+	search.After = after
+	if limit := params.Get("limit"); limit != "" {
+		search.Limit, err = strconv.Atoi(limit)
+		if err != nil || search.Limit <= 0 || search.Limit > maxSearchLimit {
+			return nil, fmt.Errorf("limit needs to be a number from 1 to %v", maxSearchLimit)
+		}
 	}
-	encoder := json.NewEncoder(w)
-	err = encoder.Encode(players)
-	return err
+	return search, nil
 }
@@ -26,28 +26,40 @@ func (conn Connection) String() string {
 type DraftSupervisor struct {
 	sync.Mutex
 	drafts map[int64]*DraftController
+	store  DraftStore
+	// Drafts that admins are changing in the store, each with a channel
+	// that's closed once the change is done.
+	configuring map[int64]chan struct{}
 }
 
-// Create a new DraftSupervisor.
-func NewSupervisor() *DraftSupervisor {
+// Create a new DraftSupervisor that loads drafts from store.
+func NewSupervisor(store DraftStore) *DraftSupervisor {
 	supervisor := DraftSupervisor{
-		drafts: map[int64]*DraftController{},
+		drafts:      map[int64]*DraftController{},
+		store:       store,
+		configuring: map[int64]chan struct{}{},
 	}
 	return &supervisor
 }
 
-// Register a new connection with supervisor. If this is the first connection for draftId it will start a new controller for the draft and invoke it's Run method in a separate goroutine.
-func (supervisor *DraftSupervisor) RegisterConnection(draftId int64, conn Connection) error {
+// Register a new connection with supervisor. If this is the first connection for draftId it will start a new controller for the draft and invoke it's Run method in a separate goroutine. resume, if not nil, is where a reconnecting client left off.
+func (supervisor *DraftSupervisor) RegisterConnection(draftId int64, conn Connection, resume *Resume) error {
 	log.Println("Registering new connection")
-	supervisor.Lock()
+	supervisor.lockConfigured(draftId)
 	ctrl, ok := supervisor.drafts[draftId]
 	if !ok {
 		log.Printf("First connection for draft id %v", draftId)
-		ctrl = NewController(draftId)
+		var err error
+		ctrl, err = NewController(draftId, supervisor.store)
+		if err != nil {
+			supervisor.Unlock()
+			log.Printf("Unable to load draft %v: %v", draftId, err)
+			return err
+		}
 		supervisor.drafts[draftId] = ctrl
 		supervisor.Unlock()
 		go supervisor.runThenRemove(draftId)
-		if err := ctrl.RegisterConnection(conn); err != nil {
+		if err := ctrl.RegisterConnection(conn, resume); err != nil {
 			log.Printf("Unable to register connection %v for draft: %v: %v", conn, draftId, err)
 			return err
 		}
@@ -56,8 +68,36 @@ func (supervisor *DraftSupervisor) RegisterConnection(draftId int64, conn Connec
 	} else {
 		supervisor.Unlock()
 		log.Printf("Adding connection: %v to already running draft id %v", conn, draftId)
-		return ctrl.RegisterConnection(conn)
+		return ctrl.RegisterConnection(conn, resume)
+	}
+}
+
+// Calls fn with draftId's controller, inside its event loop if the draft is
+// running. Otherwise fn gets a controller loaded from the store that is
+// never run.
+func (supervisor *DraftSupervisor) inspect(draftId int64, fn func(*DraftController)) error {
+	supervisor.Lock()
+	ctrl, ok := supervisor.drafts[draftId]
+	supervisor.Unlock()
+	if ok {
+		request := &inspectRequest{
+			fn:   fn,
+			done: make(chan struct{}),
+		}
+		select {
+		case ctrl.inspect <- request:
+			<-request.done
+			return nil
+		case <-ctrl.exited:
+			// Load it from the store instead.
+		}
+	}
+	ctrl, err := NewController(draftId, supervisor.store)
+	if err != nil {
+		return err
 	}
+	fn(ctrl)
+	return nil
 }
 
 func (supervisor *DraftSupervisor) runThenRemove(draftId int64) {
@@ -103,38 +143,101 @@ func (conn *Connection) writer(send <-chan *SocketMessage) {
 	conn.Ws.Close()
 }
 
+// BasePositions are the positions the player is eligible at on the field.
+// Positions adds the draft's composite slots, such as MI or U, the base
+// positions make the player eligible for. See SlotRules.
 type Player struct {
-	Id        int64    `json:"id"`
-	Firstname string   `json:"firstname"`
-	Lastname  string   `json:"lastname"`
-	Mlbteam   string   `json:"mlbteam"`
-	Positions []string `json:"positions"`
+	Id            int64    `json:"id"`
+	Firstname     string   `json:"firstname"`
+	Lastname      string   `json:"lastname"`
+	Mlbteam       string   `json:"mlbteam"`
+	BasePositions []string `json:"base_positions"`
+	Positions     []string `json:"positions"`
 }
 
 type OwnedPlayer struct {
 	*Player
 	Salary int `json:"salary"`
+	// Kept from before the draft rather than drafted.
+	Keeper bool `json:"keeper,omitempty"`
+	// The roster slot the player currently fills.
+	Slot string `json:"slot,omitempty"`
 }
 
 type TeamId int64
 
+// Adds player to team's roster.
+func (c *DraftController) addPlayer(team *Team, player *OwnedPlayer) {
+	team.Players = append(team.Players, player)
+	c.playerOwners[player.Id] = team
+	c.assignTeamSlots(team)
+}
+
+// Removes player from team's roster, making them available again.
+func (c *DraftController) dropPlayer(team *Team, player *OwnedPlayer) {
+	team.removePlayer(player)
+	delete(c.playerOwners, player.Id)
+	c.assignTeamSlots(team)
+}
+
+// Returns the team that owns playerId, or nil if the player is available.
+func (c *DraftController) ownerOf(playerId int64) *Team {
+	return c.playerOwners[playerId]
+}
+
+func (c *DraftController) isOwned(playerId int64) bool {
+	return c.ownerOf(playerId) != nil
+}
+
+// Returns the player with playerId from the store's catalog, or nil if
+// there's no such player. Clients only send player ids so the names and
+// positions they claim are never trusted.
+func (c *DraftController) lookupPlayer(playerId int64) *Player {
+	if player, ok := c.players[playerId]; ok {
+		return player
+	}
+	player, err := c.store.LoadPlayer(playerId)
+	if err != nil {
+		log.Printf("Unable to look up player %v: %v", playerId, err)
+		return nil
+	}
+	player.Positions = c.SlotRules.Positions(player.BasePositions)
+	c.players[playerId] = player
+	return player
+}
+
 type Team struct {
-	Id          TeamId `json:"id"`
-	Name        string `json:"name"`
-	playerIds   map[int64]*OwnedPlayer
+	Id          TeamId         `json:"id"`
+	Name        string         `json:"name"`
 	Players     []*OwnedPlayer `json:"players"`
+	OpenSlots   []string       `json:"open_slots"`
 	connections map[Connection]chan<- *SocketMessage
 	owners      []string
+	// Players the team wants to nominate, in order. Private to the team.
+	queue []*QueueEntry
+}
+
+func (team *Team) removePlayer(player *OwnedPlayer) {
+	for i, p := range team.Players {
+		if p == player {
+			team.Players = append(team.Players[:i], team.Players[i+1:]...)
+			return
+		}
+	}
 }
 
 func (team *Team) SendMessage(msg *SocketMessage) {
 	for _, ch := range team.connections {
-		select {
-		case ch <- msg:
-		default:
-			// Outbound buffer is full. Give up.
-			close(ch)
-		}
+		sendToChannel(ch, msg)
+	}
+}
+
+func sendToChannel(ch chan<- *SocketMessage, msg *SocketMessage) {
+	select {
+	case ch <- msg:
+	default:
+		// Outbound buffer is full. Give up.
+		close(ch)
 	}
 }
 
@@ -146,6 +249,7 @@ const (
 	PICK_PENDING_APPROVAL
 	AUCTION_IN_PROGRESS
 	DRAFT_COMPLETE
+	DRAFT_PAUSED
 )
 
 type AuctionInfo struct {
@@ -153,64 +257,88 @@ type AuctionInfo struct {
 	offeringTeam *Team
 	highBidder   *Team
 	bid          int
+	startTime    time.Time
 	endTime      time.Time
+	// Maximum bids teams asked the server to bid up to, in the order they
+	// were set.
+	proxies []*proxyBid
+	// When the offering team must pick by. Zero if nominations aren't timed.
+	nominationEndTime time.Time
+	// Time left in the auction, or on the nomination clock, when the draft
+	// was paused.
+	remaining time.Duration
 }
 
 type DraftController struct {
 	id                int64
-	Name              string           `json:"name"`
-	Teams             []*Team          `json:"teams"` // in draft order
-	owners            map[string]*Team // teams indexed by owner
+	Name              string            `json:"name"`
+	Teams             []*Team           `json:"teams"` // in draft order
+	owners            map[string]*Team  // teams indexed by owner
+	playerOwners      map[int64]*Team   // teams indexed by the players they own
+	players           map[int64]*Player // players looked up in the catalog
 	leaders           []string
-	started           bool
+	spectators        []string           // who may spectate; anyone if empty
+	started           bool               // keepers are locked once set
 	CompletedAuctions []*AuctionComplete `json:"picks"` // in draft order
 	RequiredPos       map[string]int     `json:"positions"`
+	SlotRules         SlotRules          `json:"slot_rules"`
 	SalaryCap         int                `json:"salary_cap"`
+	MinSalary         int                `json:"min_salary"`
+	MaxSalary         int                `json:"max_salary"` // 0 for no limit
+	RuleConfigs       []*RuleConfig      `json:"rules"`      // optional rules turned on
+	rules             []Rule             // built-in and optional rules
+	Settings          DraftSettings      `json:"settings"`
 	requiredPlayers   int
+	slots             []string  // one per roster spot; see rosterSlots
+	rankedPool        []*Player // auto-nominated in order
 	state             DraftState
 	auction           *AuctionInfo
+	store             DraftStore
+
+	// While paused, the state to return to on resume and the reason
+	// leaders gave for pausing.
+	pausedState DraftState
+	pauseReason string
+
+	// Sequence number of the last message sent to teams, the messages
+	// recently sent and the time the controller started, which clients
+	// use to tell sequence numbers from different controllers apart.
+	seq     int64
+	history []*sentMessage
+	epoch   int64
+
+	// Sequence number of the last journaled event.
+	journalSeq int64
+	// Set while replaying journaled events. replayTime is the time of the
+	// event being replayed.
+	replaying  bool
+	replayTime time.Time
+
+	// Connections that don't belong to a team.
+	observers map[Connection]chan<- *SocketMessage
 
 	register   chan *registerConnectionRequest
 	unregister chan Connection
 
 	// Channel this controller should listen for messages from.
 	receive chan *TeamMessage
-}
-
-func rosterHelper(playersRemaining []*Player, posRemaining map[string]int) bool {
-	if len(playersRemaining) == 0 {
-		return true
-	}
-	remaining := make(map[string]int, len(posRemaining))
-	for k, v := range posRemaining {
-		remaining[k] = v
-	}
-	player := playersRemaining[0]
-	for _, pos := range player.Positions {
-		if remaining[pos] > 0 {
-			remaining[pos]--
-			if rosterHelper(playersRemaining[1:], remaining) {
-				return true
-			}
-			remaining[pos]++
-		}
-	}
-	return false
-}
 
-func (c *DraftController) teamHasRoomFor(team *Team, player *Player) bool {
-	players := make([]*Player, len(team.Players), len(team.Players)+1)
-	for i, ownedPlayer := range team.Players {
-		players[i] = ownedPlayer.Player
-	}
-	players = append(players, player)
-	return rosterHelper(players, c.RequiredPos)
+	// Requests to look at the controller from outside its event loop,
+	// and closed when the event loop exits.
+	inspect chan *inspectRequest
+	exited  chan struct{}
 }
 
 func (c *DraftController) teamIsFull(team *Team) bool {
 	return c.requiredPlayers == len(team.Players)
 }
 
+// Returns the most team can pay for its next player while still being able
+// to fill the rest of its roster at the minimum salary. Returns 0 if the
+// team's roster is full. Positions aren't considered, so this is only an
+// upper bound: the roster rule still rejects players with no open slot
+// they're eligible for, and GetMaxBidMessage reports 0 for an auctioned
+// player that doesn't fit.
 func (c *DraftController) maxTeamCanBid(team *Team) int {
 	moneyLeft := c.SalaryCap
 	playersNeeded := c.requiredPlayers
@@ -218,108 +346,146 @@ func (c *DraftController) maxTeamCanBid(team *Team) int {
 		moneyLeft -= player.Salary
 		playersNeeded--
 	}
-	return 50 + moneyLeft - playersNeeded*50
-}
-
-func loadDraftFromDb(draftId int64) *DraftController {
-	conf := DraftController{
-		id:   draftId,
-		Name: "Test Draft",
-		Teams: []*Team{
-			&Team{
-				Id:        1,
-				Name:      "RH Team",
-				playerIds: map[int64]*OwnedPlayer{},
-				Players: []*OwnedPlayer{
-					&OwnedPlayer{
-						Player: &Player{
-							Id:        91,
-							Firstname: "Ben",
-							Lastname:  "Zobrist",
-							Mlbteam:   "Tampa Bay Rays",
-							Positions: []string{"2B", "SS", "MI", "OF", "U"},
-						},
-						Salary: 650,
-					},
-				},
-				connections: make(map[Connection]chan<- *SocketMessage),
-				owners: []string{
-					"griffin@randomhost.net",
-				},
-			},
-			&Team{
-				Id:          2,
-				Name:        "Goog Team",
-				playerIds:   map[int64]*OwnedPlayer{},
-				Players:     []*OwnedPlayer{},
-				connections: make(map[Connection]chan<- *SocketMessage),
-				owners: []string{
-					"glenng@google.com",
-				},
-			},
-		},
-		owners: map[string]*Team{},
-		leaders: []string{
-			"griffin@randomhost.net",
-		},
-		CompletedAuctions: []*AuctionComplete{},
-		RequiredPos: map[string]int{
-			"P":  10,
-			"C":  2,
-			"1B": 1,
-			"2B": 1,
-			"3B": 1,
-			"SS": 1,
-			"CI": 1,
-			"MI": 1,
-			"OF": 5,
-			"U":  2,
-		},
-		SalaryCap: 13000,
+	if playersNeeded <= 0 {
+		return 0
 	}
-	for _, c := range conf.RequiredPos {
-		conf.requiredPlayers += c
-	}
-	for _, team := range conf.Teams {
-		for _, owner := range team.owners {
-			conf.owners[owner] = team
-		}
+	max := moneyLeft - (playersNeeded-1)*c.MinSalary
+	if c.MaxSalary > 0 && max > c.MaxSalary {
+		max = c.MaxSalary
 	}
+	return max
+}
 
-	conf.auction = conf.nextAuction(conf.auction)
-	if conf.auction == nil {
-		conf.state = DRAFT_COMPLETE
-	} else {
-		conf.state = WAITING_FOR_TEAMS
+// Formats cents as dollars for messages shown to people.
+func formatMoney(cents int) string {
+	return fmt.Sprintf("$%.2f", float64(cents)/100)
+}
+
+// Tells each team the most it can bid.
+func (c *DraftController) sendMaxBids() {
+	for _, team := range c.Teams {
+		c.sendToTeam(team, SocketMessageFrom(c.GetMaxBidMessage(team)))
 	}
-	return &conf
+}
+
+type inspectRequest struct {
+	fn   func(*DraftController)
+	done chan struct{}
 }
 
 type registerConnectionRequest struct {
-	conn Connection
-	done chan error
+	conn   Connection
+	resume *Resume
+	done   chan error
 }
 
-func NewController(draftId int64) *DraftController {
+// Create a new controller for draftId, loading the draft from store and
+// recovering any auction that was in progress from the draft's journal.
+func NewController(draftId int64, store DraftStore) (*DraftController, error) {
 	log.Printf("Creating new controller for draft id %v", draftId)
-	controller := loadDraftFromDb(draftId)
+	controller, err := store.LoadDraft(draftId)
+	if err != nil {
+		return nil, err
+	}
+	if err := controller.prepare(draftId, store); err != nil {
+		return nil, err
+	}
+	events, err := store.LoadEvents(draftId)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		controller.journalSeq = events[len(events)-1].Seq
+	}
+	for _, event := range events {
+		if event.Type == teamsReadyEvent {
+			controller.started = true
+		}
+	}
+	controller.recover(events)
+	controller.epoch = time.Now().UnixNano()
 	controller.register = make(chan *registerConnectionRequest)
 	controller.unregister = make(chan Connection)
 	controller.receive = make(chan *TeamMessage, 256)
-	return controller
+	controller.inspect = make(chan *inspectRequest)
+	controller.exited = make(chan struct{})
+	return controller, nil
 }
 
-func (c *DraftController) RegisterConnection(conn Connection) error {
+// Builds the indexes and initial auction for a controller loaded by store.
+func (c *DraftController) prepare(draftId int64, store DraftStore) error {
+	if len(c.Teams) == 0 {
+		return fmt.Errorf("draft %v has no teams", draftId)
+	}
+	c.id = draftId
+	c.store = store
+	c.owners = map[string]*Team{}
+	c.playerOwners = map[int64]*Team{}
+	c.players = map[int64]*Player{}
+	c.observers = make(map[Connection]chan<- *SocketMessage)
+	if len(c.SlotRules) == 0 {
+		c.SlotRules = DefaultSlotRules
+	}
+	for _, team := range c.Teams {
+		for _, owner := range team.owners {
+			c.owners[owner] = team
+		}
+		for _, player := range team.Players {
+			if owner, ok := c.playerOwners[player.Id]; ok {
+				return fmt.Errorf("player %v is on both %v and %v", player.Id, owner.Name, team.Name)
+			}
+			c.playerOwners[player.Id] = team
+			c.players[player.Id] = player.Player
+			player.Positions = c.SlotRules.Positions(player.BasePositions)
+		}
+		for _, entry := range team.queue {
+			c.players[entry.Player.Id] = entry.Player
+			entry.Player.Positions = c.SlotRules.Positions(entry.Player.BasePositions)
+		}
+	}
+	for _, player := range c.rankedPool {
+		c.players[player.Id] = player
+		player.Positions = c.SlotRules.Positions(player.BasePositions)
+	}
+	c.MinSalary = effectiveMinSalary(c.MinSalary)
+	c.rules = append([]Rule{}, builtinRules...)
+	for _, config := range c.RuleConfigs {
+		rule, err := newRule(config)
+		if err != nil {
+			return fmt.Errorf("draft %v: %v", draftId, err)
+		}
+		c.rules = append(c.rules, rule)
+	}
+	c.slots = rosterSlots(c.RequiredPos)
+	c.requiredPlayers = len(c.slots)
+	for _, team := range c.Teams {
+		c.assignTeamSlots(team)
+	}
+
+	c.auction = c.resumeAuction()
+	if c.auction == nil {
+		c.state = DRAFT_COMPLETE
+	} else {
+		c.state = WAITING_FOR_TEAMS
+	}
+	return nil
+}
+
+// Register conn with the controller. If resume is not nil the controller
+// tries to send only the messages conn missed since resume.
+func (c *DraftController) RegisterConnection(conn Connection, resume *Resume) error {
 	done := make(chan error)
 	c.register <- &registerConnectionRequest{
-		conn: conn,
-		done: done,
+		conn:   conn,
+		resume: resume,
+		done:   done,
 	}
 	return <-done
 }
 
 func (c *DraftController) Run() {
 	log.Printf("Running draft %v", c.id)
+	defer close(c.exited)
 	for {
 		if !c.EventLoop() {
 			return
@@ -332,61 +498,105 @@ func (c *DraftController) EventLoop() bool {
 	case request := <-c.register:
 		conn := request.conn
 		team, ok := c.owners[conn.User.Email]
-		if !ok {
-			request.done <- errors.New(conn.User.Email + " is not an owner of a team.")
+		if !c.canView(conn.User.Email) {
+			request.done <- errors.New(conn.User.Email + " may not view this draft")
 			return true
 		}
 		sendCh := make(chan *SocketMessage, 512)
-		team.connections[conn] = sendCh
-		log.Printf("Registered connection %v for team %v", conn, team.Name)
+		if ok {
+			team.connections[conn] = sendCh
+			log.Printf("Registered connection %v for team %v", conn, team.Name)
+		} else {
+			c.observers[conn] = sendCh
+			log.Printf("Registered teamless connection %v", conn)
+		}
+		c.journal(conn.User.Email, registerEvent, nil)
 		go conn.reader(c.receive, c.unregister)
 		go conn.writer(sendCh)
-		summary := DraftSummary{
-			DraftController: c,
-			Team:            team.Id,
+		if !c.resend(conn, team, sendCh, request.resume) {
+			c.sendSnapshot(conn, team, sendCh)
 		}
-		sendCh <- SocketMessageFrom(summary)
 		request.done <- nil
-		switch {
-		case c.state == DRAFT_COMPLETE:
-			sendCh <- SocketMessageFrom(DraftComplete{})
-		case c.state == WAITING_FOR_TEAMS:
+		if c.state == WAITING_FOR_TEAMS {
 			joinMsg := c.GetJoinLeaveMessage()
 			if len(joinMsg.Disconnected) > 0 {
 				c.broadcast(SocketMessageFrom(joinMsg))
 				return true
 			}
+			c.journal("", teamsReadyEvent, nil)
 			c.startAuction(c.auction)
-		case c.state == WAITING_FOR_PICK:
-			sendCh <- SocketMessageFrom(c.GetWaitingForPickMessage())
-		case c.state == PICK_PENDING_APPROVAL:
-			if team == c.auction.offeringTeam {
-				sendCh <- SocketMessageFrom(c.GetPickPendingApprovalMessage())
-			} else {
-				sendCh <- SocketMessageFrom(c.GetWaitingForPickMessage())
-			}
-		case c.state == AUCTION_IN_PROGRESS:
-			sendCh <- SocketMessageFrom(c.GetAuctionMessage())
 		}
 	case conn := <-c.unregister:
-		team := c.owners[conn.User.Email]
-		delete(team.connections, conn)
-		log.Printf("Unregistering connection %v for team %v", conn, team.Name)
+		team := c.removeConnection(conn)
 		if c.numConnections() == 0 {
 			log.Printf("Last connection closed. Exiting.")
 			return false
 		}
 		c.broadcast(SocketMessageFrom(c.GetJoinLeaveMessage()))
+		if c.state == WAITING_FOR_PICK && team == c.auction.offeringTeam {
+			c.nominateIfDisconnected()
+		}
 	case msg := <-c.receive:
 		c.handleMessage(msg)
+	case request := <-c.inspect:
+		request.fn(c)
+		close(request.done)
 	case <-c.auctionExpired():
 		log.Println("Auction completed")
 		c.finishAuction()
+	case <-c.nominationExpired():
+		log.Println("Nomination clock expired")
+		c.nominationTimedOut()
 	}
 	return true
 }
 
+// Sends a new connection everything it needs to know about the draft.
+func (c *DraftController) sendSnapshot(conn Connection, team *Team, sendCh chan<- *SocketMessage) {
+	summary := DraftSummary{
+		DraftController: c,
+		Leader:          c.isLeader(conn.User.Email),
+		Started:         c.started,
+		Epoch:           c.epoch,
+	}
+	if team != nil {
+		summary.Team = team.Id
+	}
+	summaryMsg := SocketMessageFrom(summary)
+	// Lets the client resume from here if it reconnects.
+	summaryMsg.Seq = c.seq
+	sendCh <- summaryMsg
+	if team != nil {
+		sendCh <- SocketMessageFrom(team.GetNominationQueueMessage())
+		sendCh <- SocketMessageFrom(c.GetMaxBidMessage(team))
+	}
+	switch {
+	case c.state == DRAFT_COMPLETE:
+		sendCh <- SocketMessageFrom(DraftComplete{})
+	case c.state == WAITING_FOR_PICK:
+		sendCh <- SocketMessageFrom(c.GetWaitingForPickMessage())
+	case c.state == PICK_PENDING_APPROVAL:
+		if team == c.auction.offeringTeam || c.isLeader(conn.User.Email) {
+			sendCh <- SocketMessageFrom(c.GetPickPendingApprovalMessage())
+		} else {
+			sendCh <- SocketMessageFrom(c.GetWaitingForPickMessage())
+		}
+	case c.state == AUCTION_IN_PROGRESS:
+		sendCh <- SocketMessageFrom(c.GetAuctionMessage())
+		if proxy := c.auction.proxyFor(team); team != nil && proxy != nil {
+			sendCh <- SocketMessageFrom(ProxyBidAccepted{
+				Player: c.auction.player,
+				Max:    proxy.max,
+			})
+		}
+	case c.state == DRAFT_PAUSED:
+		sendCh <- SocketMessageFrom(c.GetDraftPausedMessage())
+	}
+}
+
 func (c *DraftController) auctionExpired() <-chan time.Time {
+	// Paused auctions never expire; resuming restores the time that was
+	// remaining when the draft was paused.
 	if c.state != AUCTION_IN_PROGRESS {
 		return make(chan time.Time)
 	}
@@ -401,10 +611,18 @@ func (c *DraftController) finishAuction() {
 		},
 		OfferingTeam: c.auction.offeringTeam.Id,
 		WinningTeam:  c.auction.highBidder.Id,
+		PickNumber:   len(c.CompletedAuctions) + 1,
+		StartTime:    c.auction.startTime,
+		EndTime:      c.auction.endTime,
+	}
+	if err := c.recordCompletedAuction(&msg); err != nil {
+		c.pauseForUnsavedPick(err)
+		return
 	}
-	c.auction.highBidder.Players = append(c.auction.highBidder.Players, msg.Player)
-	c.recordCompletedAuction(&msg)
-	c.broadcast(SocketMessageFrom(msg))
+	c.journal("", auctionExpiredEvent, nil)
+	c.addPlayer(c.auction.highBidder, msg.Player)
+	c.broadcastAuctionComplete(msg)
+	c.removeFromQueues(msg.Player.Id)
 	nextAuction := c.nextAuction(c.auction)
 	if nextAuction == nil {
 		c.finishDraft()
@@ -413,32 +631,43 @@ func (c *DraftController) finishAuction() {
 	}
 }
 
+// Tells everyone about auction along with the winning team's new lineup,
+// which may move players already on the team to other slots.
+func (c *DraftController) broadcastAuctionComplete(auction AuctionComplete) {
+	auction.Lineup = c.lineup(c.teamById(auction.WinningTeam))
+	c.broadcast(SocketMessageFrom(auction))
+}
+
 func (c *DraftController) finishDraft() {
+	c.journal("", draftCompleteEvent, nil)
 	c.broadcast(SocketMessageFrom(DraftComplete{}))
 	c.state = DRAFT_COMPLETE
 }
 
 func (c *DraftController) startAuction(auction *AuctionInfo) {
+	c.started = true
 	c.auction = auction
+	if clock := c.Settings.nominationDuration(); clock > 0 {
+		c.auction.nominationEndTime = c.now().Add(clock)
+	}
 	log.Println("WAITING_FOR_PICK")
 	c.state = WAITING_FOR_PICK
 	c.broadcast(SocketMessageFrom(c.GetWaitingForPickMessage()))
+	c.sendMaxBids()
+	c.nominateIfDisconnected()
 }
 
 func (c *DraftController) nextAuction(current *AuctionInfo) *AuctionInfo {
 	log.Printf("nextAuction(%v)", current)
-	if current == nil {
-		return &AuctionInfo{
-			offeringTeam: c.Teams[0],
-		}
-	}
-
-	// Find out where the current team fell in the draft order.
+	// Find out where the current team fell in the draft order. With no
+	// current auction start from the first team.
 	currTeamDraftPos := -1
-	for i, team := range c.Teams {
-		if team == current.offeringTeam {
-			currTeamDraftPos = i
-			break
+	if current != nil {
+		for i, team := range c.Teams {
+			if team == current.offeringTeam {
+				currTeamDraftPos = i
+				break
+			}
 		}
 	}
 
@@ -453,15 +682,50 @@ func (c *DraftController) nextAuction(current *AuctionInfo) *AuctionInfo {
 	return nil
 }
 
-func (c *DraftController) StartBidding(pick Pick) {
-	c.auction.bid = pick.Bid
-	c.auction.player = pick.Player
+// Returns the auction that follows the last completed auction, or nil if
+// every team is full.
+func (c *DraftController) resumeAuction() *AuctionInfo {
+	var current *AuctionInfo
+	for i := len(c.CompletedAuctions) - 1; i >= 0; i-- {
+		// Players assigned by leaders weren't offered by any team.
+		if offeringTeam := c.CompletedAuctions[i].OfferingTeam; offeringTeam != 0 {
+			current = &AuctionInfo{
+				offeringTeam: c.teamById(offeringTeam),
+			}
+			break
+		}
+	}
+	return c.nextAuction(current)
+}
+
+// Returns the auction that follows undoing an auction offered by
+// offeringTeam: the same team nominates again, unless a leader has since
+// filled its roster. Returns nil if offeringTeam isn't a team.
+func (c *DraftController) auctionAfterUndo(offeringTeam TeamId) *AuctionInfo {
+	team := c.teamById(offeringTeam)
+	if team == nil {
+		return nil
+	}
+	auction := &AuctionInfo{
+		offeringTeam: team,
+	}
+	if c.teamIsFull(team) {
+		return c.nextAuction(auction)
+	}
+	return auction
+}
+
+func (c *DraftController) StartBidding(player *Player, bid int) {
+	c.auction.bid = bid
+	c.auction.player = player
 	c.auction.highBidder = c.auction.offeringTeam
-	c.auction.endTime = time.Now().Add(30 * time.Second)
+	c.auction.startTime = c.now()
+	c.auction.endTime = c.auction.startTime.Add(c.Settings.auctionDuration())
 	log.Println("AUCTION_IN_PROGRESS")
 	c.state = AUCTION_IN_PROGRESS
 	msg := SocketMessageFrom(c.GetAuctionMessage())
 	c.broadcast(msg)
+	c.sendMaxBids()
 }
 
 func (c *DraftController) GetJoinLeaveMessage() TeamJoinLeaveMessage {
@@ -481,12 +745,14 @@ func (c *DraftController) GetJoinLeaveMessage() TeamJoinLeaveMessage {
 
 func (c *DraftController) GetWaitingForPickMessage() WaitingForPick {
 	return WaitingForPick{
-		Team: c.auction.offeringTeam.Id,
+		Team:    c.auction.offeringTeam.Id,
+		EndTime: c.auction.nominationEndTime,
 	}
 }
 
 func (c *DraftController) GetPickPendingApprovalMessage() PickPendingApproval {
 	return PickPendingApproval{
+		Team:   c.auction.offeringTeam.Id,
 		Player: c.auction.player,
 		Bid:    c.auction.bid,
 	}
@@ -497,29 +763,72 @@ func (c *DraftController) GetAuctionMessage() Auction {
 		Player:  c.auction.player,
 		Team:    c.auction.highBidder.Id,
 		Bid:     c.auction.bid,
+		MinBid:  c.minimumBid(),
 		EndTime: c.auction.endTime,
 	}
 }
 
+func (c *DraftController) GetMaxBidMessage(team *Team) MaxBid {
+	msg := MaxBid{
+		Max: c.maxTeamCanBid(team),
+	}
+	if c.state == AUCTION_IN_PROGRESS {
+		msg.Player = c.auction.player.Id
+		if !c.teamHasRoomFor(team, c.auction.player) {
+			msg.Max = 0
+		}
+	}
+	return msg
+}
+
+// Returns the smallest bid that would beat the current high bid.
+func (c *DraftController) minimumBid() int {
+	return c.auction.bid + c.Settings.bidIncrement(c.auction.bid)
+}
+
+func (c *DraftController) GetDraftPausedMessage() DraftPaused {
+	return DraftPaused{
+		Reason: c.pauseReason,
+	}
+}
+
+// Sends reply to whoever sent msg: every connection of team, or only the
+// sending connection if it doesn't belong to a team.
+func (c *DraftController) reply(team *Team, msg *TeamMessage, reply *SocketMessage) {
+	if team != nil {
+		c.sendToTeam(team, reply)
+	} else if ch := c.connectionChannel(msg.Connection); ch != nil {
+		sendToChannel(ch, reply)
+	}
+}
+
 func (c *DraftController) broadcast(msg *SocketMessage) {
+	msg = c.stamp(msg, nil, false)
 	for _, team := range c.Teams {
 		team.SendMessage(msg)
 	}
+	for _, ch := range c.observers {
+		sendToChannel(ch, msg)
+	}
 }
 
 func (c *DraftController) handleMessage(msg *TeamMessage) {
 	team := c.owners[msg.User.Email]
 	//log.Printf("Message received from connection %v team %v", msg, team.Name)
+	if team == nil && msg.SocketMessage.Type != "TimeRequest" && !(leaderCommands[msg.SocketMessage.Type] && c.isLeader(msg.User.Email)) {
+		c.rejectTeamless(msg)
+		return
+	}
+	if msg.SocketMessage.Type != "TimeRequest" {
+		c.journal(msg.User.Email, msg.SocketMessage.Type, msg.SocketMessage.Data)
+	}
 	switch {
 	case msg.SocketMessage.Type == "TimeRequest":
 		response := SocketMessageFrom(TimeResponse{
 			Time: time.Now(),
 		})
-		select {
-		case team.connections[msg.Connection] <- response:
-		default:
-			// Outbound buffer is full. Give up.
-			close(team.connections[msg.Connection])
+		if ch := c.connectionChannel(msg.Connection); ch != nil {
+			sendToChannel(ch, response)
 		}
 	case msg.SocketMessage.Type == "Pick":
 		var pick Pick
@@ -528,43 +837,86 @@ func (c *DraftController) handleMessage(msg *TeamMessage) {
 			log.Println("Invalid message")
 			return
 		}
+		player := c.lookupPlayer(pick.PlayerId)
+		if c.state == DRAFT_PAUSED {
+			msg := SocketMessageFrom(PlayerRejected{
+				Player: player,
+				Bid:    pick.Bid,
+				Reason: "The draft is paused",
+			})
+			c.sendToTeam(team, msg)
+			return
+		}
 		if c.state != WAITING_FOR_PICK {
 			msg := SocketMessageFrom(PlayerRejected{
-				Player: pick.Player,
+				Player: player,
 				Bid:    pick.Bid,
 				Reason: "Pick received when not waiting for pick",
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
 			return
 		}
 		if team != c.auction.offeringTeam {
 			msg := SocketMessageFrom(PlayerRejected{
-				Player: pick.Player,
+				Player: player,
 				Bid:    pick.Bid,
 				Reason: "Not expecting pick from your team",
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
 			return
 		}
-		if maxBid := c.maxTeamCanBid(team); maxBid < pick.Bid {
+		if player == nil {
 			msg := SocketMessageFrom(PlayerRejected{
-				Player: pick.Player,
 				Bid:    pick.Bid,
-				Reason: fmt.Sprintf("You cannot bid more than $%.2f", float32(maxBid/100)),
+				Reason: "Unknown player",
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
 			return
 		}
-		if !c.teamHasRoomFor(team, pick.Player) {
+		if rejection := c.checkRules(&Action{
+			Kind:   NominateAction,
+			Team:   team,
+			Player: player,
+			Amount: pick.Bid,
+		}); rejection != nil {
 			msg := SocketMessageFrom(PlayerRejected{
-				Player: pick.Player,
+				Player: player,
 				Bid:    pick.Bid,
-				Reason: "No room for player on your roster",
+				Rule:   rejection.Rule,
+				Reason: rejection.Reason,
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
 			return
 		}
-		c.StartBidding(pick)
+		if c.Settings.RequireApproval {
+			c.requestApproval(player, pick.Bid)
+		} else {
+			c.StartBidding(player, pick.Bid)
+		}
+	case msg.SocketMessage.Type == "ApprovePick":
+		c.approvePick(team, msg)
+	case msg.SocketMessage.Type == "RejectPick":
+		c.rejectPick(team, msg)
+	case msg.SocketMessage.Type == "SetApprovalRequired":
+		c.setApprovalRequired(team, msg)
+	case msg.SocketMessage.Type == "SetAuctionTiming":
+		c.setAuctionTiming(team, msg)
+	case msg.SocketMessage.Type == "SetSpectators":
+		c.setSpectators(team, msg)
+	case msg.SocketMessage.Type == "AssignPlayer":
+		c.assignPlayer(team, msg)
+	case msg.SocketMessage.Type == "SetKeeper":
+		c.setKeeper(team, msg)
+	case msg.SocketMessage.Type == "RemoveKeeper":
+		c.removeKeeper(team, msg)
+	case msg.SocketMessage.Type == "KickConnection":
+		c.kickConnection(team, msg)
+	case msg.SocketMessage.Type == "PauseDraft":
+		c.pauseDraft(team, msg)
+	case msg.SocketMessage.Type == "ResumeDraft":
+		c.resumeDraft(team, msg)
+	case msg.SocketMessage.Type == "UndoAuction":
+		c.undoAuction(team, msg)
 	case msg.SocketMessage.Type == "Bid":
 		var bid Bid
 		err := json.Unmarshal(msg.SocketMessage.Data, &bid)
@@ -572,70 +924,112 @@ func (c *DraftController) handleMessage(msg *TeamMessage) {
 			log.Println("Invalid message")
 			return
 		}
-		if c.state != AUCTION_IN_PROGRESS {
-			msg := SocketMessageFrom(BidRejected{
-				Player: bid.Player,
-				Bid:    bid.Bid,
-				Reason: "No auction is in progress",
-			})
-			team.SendMessage(msg)
-			log.Println("Bid received when no auction is in progress")
-			return
-		}
-		if bid.Player.Id != c.auction.player.Id {
+		player := c.lookupPlayer(bid.PlayerId)
+		if c.state == DRAFT_PAUSED {
 			msg := SocketMessageFrom(BidRejected{
-				Player: bid.Player,
+				Player: player,
 				Bid:    bid.Bid,
-				Reason: fmt.Sprintf("Player is not up for auction"),
+				Reason: "The draft is paused",
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
 			return
 		}
-		if maxBid := c.maxTeamCanBid(team); maxBid < bid.Bid {
+		if c.state != AUCTION_IN_PROGRESS {
 			msg := SocketMessageFrom(BidRejected{
-				Player: bid.Player,
+				Player: player,
 				Bid:    bid.Bid,
-				Reason: fmt.Sprintf("You cannot bid more than $%.2f", maxBid),
+				Reason: "No auction is in progress",
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
+			log.Println("Bid received when no auction is in progress")
 			return
 		}
-		if bid.Bid < c.auction.bid {
+		if player == nil || player.Id != c.auction.player.Id {
 			msg := SocketMessageFrom(BidRejected{
-				Player: bid.Player,
+				Player: player,
 				Bid:    bid.Bid,
-				Reason: "Bid is not the highest bid",
+				Reason: fmt.Sprintf("Player is not up for auction"),
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
 			return
 		}
-		if !c.teamHasRoomFor(team, bid.Player) {
+		if rejection := c.checkRules(&Action{
+			Kind:   BidAction,
+			Team:   team,
+			Player: player,
+			Amount: bid.Bid,
+		}); rejection != nil {
 			msg := SocketMessageFrom(BidRejected{
-				Player: bid.Player,
+				Player: player,
 				Bid:    bid.Bid,
-				Reason: "No room for player on your roster",
+				Rule:   rejection.Rule,
+				Reason: rejection.Reason,
 			})
-			team.SendMessage(msg)
+			c.sendToTeam(team, msg)
 			return
 		}
-		c.auction.bid = bid.Bid
-		c.auction.highBidder = team
-		if c.auction.endTime.Sub(time.Now()) < 20*time.Second {
-			c.auction.endTime = time.Now().Add(20 * time.Second)
-		}
-		msg := SocketMessageFrom(c.GetAuctionMessage())
-		c.broadcast(msg)
+		c.acceptBid(team, bid.Bid)
+		c.resolveProxies()
+	case msg.SocketMessage.Type == "ProxyBid":
+		c.handleProxyBid(team, msg)
+	case msg.SocketMessage.Type == "SetNominationQueue":
+		c.setNominationQueue(team, msg)
 	}
 }
 
+// Makes team the high bidder at amount and tells everyone.
+func (c *DraftController) acceptBid(team *Team, amount int) {
+	c.auction.bid = amount
+	c.auction.highBidder = team
+	if c.auction.endTime.Sub(c.now()) < c.Settings.extensionThreshold() {
+		c.auction.endTime = c.now().Add(c.Settings.bidExtension())
+	}
+	msg := SocketMessageFrom(c.GetAuctionMessage())
+	c.broadcast(msg)
+}
+
 func (c *DraftController) numConnections() int {
-	connCount := 0
+	connCount := len(c.observers)
 	for _, team := range c.Teams {
 		connCount += len(team.connections)
 	}
 	return connCount
 }
 
-func (c *DraftController) recordCompletedAuction(auction *AuctionComplete) {
+func (c *DraftController) teamById(id TeamId) *Team {
+	for _, team := range c.Teams {
+		if team.Id == id {
+			return team
+		}
+	}
+	return nil
+}
+
+// Persists auction and appends it to CompletedAuctions. The auction is
+// written before it's broadcast so a restarted controller never loses
+// results that teams have already seen. Returns an error, without
+// changing CompletedAuctions, if the auction couldn't be persisted. It
+// isn't retried here so a slow store doesn't stall the event loop.
+func (c *DraftController) recordCompletedAuction(auction *AuctionComplete) error {
+	// Replayed auctions were persisted when they originally completed.
+	if !c.replaying {
+		if err := c.store.RecordAuction(c.id, auction); err != nil {
+			log.Printf("Unable to persist pick %v for draft %v: %v", auction.PickNumber, c.id, err)
+			return err
+		}
+	}
 	c.CompletedAuctions = append(c.CompletedAuctions, auction)
+	return nil
+}
+
+// Pauses the auction in progress after its result couldn't be saved, so
+// later picks aren't lost too. Resuming the draft ends the auction again,
+// which tries to save it again.
+func (c *DraftController) pauseForUnsavedPick(err error) {
+	log.Println("DRAFT_PAUSED")
+	c.auction.remaining = 0
+	c.pausedState = c.state
+	c.pauseReason = fmt.Sprintf("Unable to save the last pick: %v", err)
+	c.state = DRAFT_PAUSED
+	c.broadcast(SocketMessageFrom(c.GetDraftPausedMessage()))
 }
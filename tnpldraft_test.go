@@ -1,22 +1,1163 @@
 package tnpldraft
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ggriffiniii/googleauth"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	auctions []*AuctionComplete
+	events   []*JournalEvent
+	// Returned by RecordAuction if set.
+	recordErr error
+	// How many times RecordAuction has been called.
+	recordCalls int
+	// The config of the last draft created.
+	created *DraftConfig
+}
+
+// The players in fakeStore's catalog.
+var testPlayers = map[int64]*Player{
+	2: {Id: 2, Firstname: "Clayton", Lastname: "Kershaw", Mlbteam: "LAD", BasePositions: []string{"P"}, Positions: []string{"P"}},
+	3: {Id: 3, Firstname: "Max", Lastname: "Scherzer", Mlbteam: "WSH", BasePositions: []string{"P"}, Positions: []string{"P"}},
+	4: {Id: 4, Firstname: "Chris", Lastname: "Sale", Mlbteam: "BOS", BasePositions: []string{"P"}, Positions: []string{"P"}},
+}
+
+func (s *fakeStore) QueryPlayers(query *PlayerQuery, keep func(*Player) bool, limit int) ([]*Player, error) {
+	players := []*Player{}
+	for id := query.After + 1; id <= 4 && len(players) < limit; id++ {
+		if player, ok := testPlayers[id]; ok && keep(player) {
+			players = append(players, player)
+		}
+	}
+	return players, nil
+}
+
+func (s *fakeStore) LoadPlayer(playerId int64) (*Player, error) {
+	player, ok := testPlayers[playerId]
+	if !ok {
+		return nil, fmt.Errorf("no player with id %v", playerId)
+	}
+	return player, nil
+}
+
+func (s *fakeStore) LoadDraft(draftId int64) (*DraftController, error) {
+	controller := &DraftController{
+		Teams: []*Team{
+			newTestTeam(1, "one@example.com"),
+			newTestTeam(2, "two@example.com"),
+		},
+		leaders:           []string{"one@example.com"},
+		CompletedAuctions: []*AuctionComplete{},
+		RequiredPos:       map[string]int{"P": 2, "U": 1},
+		SalaryCap:         1000,
+		Settings: DraftSettings{
+			AuctionSeconds:            30,
+			BidExtensionSeconds:       20,
+			ExtensionThresholdSeconds: 20,
+		},
+	}
+	for _, recorded := range s.auctions {
+		auction := *recorded
+		auction.Player = &OwnedPlayer{
+			Player: recorded.Player.Player,
+			Salary: recorded.Player.Salary,
+		}
+		team := controller.Teams[auction.WinningTeam-1]
+		team.Players = append(team.Players, auction.Player)
+		controller.CompletedAuctions = append(controller.CompletedAuctions, &auction)
+	}
+	return controller, nil
+}
+
+func (s *fakeStore) RecordAuction(draftId int64, auction *AuctionComplete) error {
+	s.recordCalls++
+	if s.recordErr != nil {
+		return s.recordErr
+	}
+	s.auctions = append(s.auctions, auction)
+	return nil
+}
+
+func (s *fakeStore) DeleteAuction(draftId int64, pickNumber int) error {
+	for i, auction := range s.auctions {
+		if auction.PickNumber == pickNumber {
+			s.auctions = append(s.auctions[:i], s.auctions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) AppendEvent(draftId int64, event *JournalEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *fakeStore) LoadEvents(draftId int64) ([]*JournalEvent, error) {
+	return s.events, nil
+}
+
+func (s *fakeStore) SaveQueue(teamId TeamId, entries []*QueueEntry) error {
+	return nil
+}
+
+func (s *fakeStore) SaveSpectators(draftId int64, emails []string) error {
+	return nil
+}
+
+func (s *fakeStore) SaveSettings(draftId int64, settings *DraftSettings) error {
+	return nil
+}
+
+func (s *fakeStore) SaveKeeper(teamId TeamId, keeper *OwnedPlayer) error {
+	return nil
+}
+
+func (s *fakeStore) DeleteKeeper(teamId TeamId, playerId int64) error {
+	return nil
+}
+
+func (s *fakeStore) CreateDraft(config *DraftConfig) (int64, error) {
+	s.created = config
+	return 6, nil
+}
+
+func (s *fakeStore) UpdateDraft(draftId int64, config *DraftConfig) error {
+	return nil
+}
+
+func (s *fakeStore) DeleteDraft(draftId int64) error {
+	return nil
+}
+
+func (s *fakeStore) CreateTeam(draftId int64, config *TeamConfig) (TeamId, error) {
+	return 3, nil
+}
+
+func (s *fakeStore) UpdateTeam(teamId TeamId, config *TeamConfig) error {
+	return nil
+}
+
+func (s *fakeStore) DeleteTeam(teamId TeamId) error {
+	return nil
+}
+
+func (s *fakeStore) AddOwner(teamId TeamId, email string) error {
+	return nil
+}
+
+func (s *fakeStore) RemoveOwner(teamId TeamId, email string) error {
+	return nil
+}
+
+func (s *fakeStore) AddLeader(draftId int64, email string) error {
+	return nil
+}
+
+func (s *fakeStore) RemoveLeader(draftId int64, email string) error {
+	return nil
+}
+
+func newTestTeam(id TeamId, owner string) *Team {
+	return &Team{
+		Id:          id,
+		Players:     []*OwnedPlayer{},
+		connections: make(map[Connection]chan<- *SocketMessage),
+		owners:      []string{owner},
+	}
+}
+
+func newTestController(t *testing.T) *DraftController {
+	controller, err := NewController(5, &fakeStore{})
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+	return controller
+}
+
+// Returns a controller that has started the draft and is waiting for team 1
+// to pick.
+func newStartedTestController(t *testing.T) *DraftController {
+	controller := newTestController(t)
+	controller.journal("", teamsReadyEvent, nil)
+	controller.startAuction(controller.auction)
+	return controller
+}
+
+// Delivers msg to controller as if it was sent by email.
+func sendTestMessage(controller *DraftController, email string, msg interface{}) {
+	controller.handleMessage(&TeamMessage{
+		Connection: Connection{
+			User: &googleauth.Profile{Email: email},
+		},
+		SocketMessage: *SocketMessageFrom(msg),
+	})
+}
 
 func TestTeamToPickNext(t *testing.T) {
-	controller := NewController(5)
-	team := controller.TeamToPickNext()
-	if team.id != 1 {
-		t.Errorf("fail")
-	}
-	pick := draftPick{
-		player:       &player{id: 2},
-		offeringTeam: team,
-		winningTeam:  team,
-		winningBid:   13.00,
-	}
-	controller.RecordPick(&pick)
-	team = controller.TeamToPickNext()
-	if team.id != 2 {
-		t.Errorf("fail2")
+	controller := newTestController(t)
+	team := controller.auction.offeringTeam
+	if team.Id != 1 {
+		t.Errorf("first team to pick = %v, want 1", team.Id)
+	}
+	controller.StartBidding(&Player{Id: 2, Positions: []string{"P"}}, 1300)
+	controller.finishAuction()
+	if recorded := controller.store.(*fakeStore).auctions; len(recorded) != 1 || recorded[0].PickNumber != 1 {
+		t.Errorf("recorded auctions = %v, want pick 1", recorded)
+	}
+	team = controller.auction.offeringTeam
+	if team.Id != 2 {
+		t.Errorf("second team to pick = %v, want 2", team.Id)
+	}
+}
+
+func TestReplayDraft(t *testing.T) {
+	controller := newStartedTestController(t)
+	player := testPlayers[2]
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: player.Id, Bid: 150})
+	controller.finishAuction()
+
+	store := controller.store.(*fakeStore)
+	replayed, err := replayDraft(store, 5, store.events[1].Seq)
+	if err != nil {
+		t.Fatalf("replayDraft: %v", err)
+	}
+	if replayed.state != AUCTION_IN_PROGRESS || replayed.auction.bid != 100 {
+		t.Errorf("after pick: state = %v, bid = %v; want auction in progress at 100", replayed.state, replayed.auction.bid)
+	}
+
+	replayed, err = replayDraft(store, 5, store.events[len(store.events)-1].Seq)
+	if err != nil {
+		t.Fatalf("replayDraft: %v", err)
+	}
+	if len(replayed.CompletedAuctions) != 1 {
+		t.Fatalf("replayed %v auctions, want 1", len(replayed.CompletedAuctions))
+	}
+	if auction := replayed.CompletedAuctions[0]; auction.WinningTeam != 2 || auction.Player.Salary != 150 {
+		t.Errorf("replayed auction won by %v for %v, want team 2 for 150", auction.WinningTeam, auction.Player.Salary)
+	}
+	if len(store.auctions) != 1 {
+		t.Errorf("replay persisted auctions; store has %v, want 1", len(store.auctions))
+	}
+}
+
+func TestUnsavedPick(t *testing.T) {
+	controller := newStartedTestController(t)
+	store := controller.store.(*fakeStore)
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	store.recordErr = fmt.Errorf("database unavailable")
+	controller.finishAuction()
+	if controller.state != DRAFT_PAUSED || len(controller.CompletedAuctions) != 0 || len(controller.teamById(1).Players) != 0 {
+		t.Fatalf("unsaved pick applied: state = %v, picks = %v", controller.state, controller.CompletedAuctions)
+	}
+	if store.recordCalls != 1 {
+		t.Errorf("pick saved %v times before pausing, want 1 so the event loop doesn't wait", store.recordCalls)
+	}
+	store.recordErr = nil
+	sendTestMessage(controller, "one@example.com", ResumeDraft{})
+	if controller.state != AUCTION_IN_PROGRESS || controller.auction.endTime.After(time.Now()) {
+		t.Fatalf("resumed to %v ending at %v, want an auction that has already ended", controller.state, controller.auction.endTime)
+	}
+	controller.finishAuction()
+	if len(store.auctions) != 1 || store.auctions[0].PickNumber != 1 {
+		t.Errorf("recorded auctions after resuming = %v, want pick 1", store.auctions)
+	}
+}
+
+func TestPickApproval(t *testing.T) {
+	controller := newStartedTestController(t)
+	controller.Settings.RequireApproval = true
+	player := testPlayers[2]
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	if controller.state != PICK_PENDING_APPROVAL {
+		t.Fatalf("state after pick = %v, want PICK_PENDING_APPROVAL", controller.state)
+	}
+	sendTestMessage(controller, "two@example.com", ApprovePick{})
+	if controller.state != PICK_PENDING_APPROVAL {
+		t.Errorf("non-leader approved pick")
+	}
+	sendTestMessage(controller, "one@example.com", RejectPick{Reason: "Retired"})
+	if controller.state != WAITING_FOR_PICK || controller.auction.player != nil {
+		t.Errorf("state after rejection = %v, want WAITING_FOR_PICK", controller.state)
+	}
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	sendTestMessage(controller, "one@example.com", ApprovePick{})
+	if controller.state != AUCTION_IN_PROGRESS || controller.auction.player.Id != 2 {
+		t.Errorf("state after approval = %v, want AUCTION_IN_PROGRESS", controller.state)
+	}
+}
+
+func TestUndoAuction(t *testing.T) {
+	controller := newStartedTestController(t)
+	player := testPlayers[2]
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: player.Id, Bid: 150})
+	controller.finishAuction()
+
+	sendTestMessage(controller, "one@example.com", UndoAuction{})
+	if len(controller.CompletedAuctions) != 0 {
+		t.Errorf("%v auctions after undo, want 0", len(controller.CompletedAuctions))
+	}
+	if team := controller.teamById(2); len(team.Players) != 0 {
+		t.Errorf("team 2 has %v players after undo, want 0", len(team.Players))
+	}
+	if controller.state != WAITING_FOR_PICK || controller.auction.offeringTeam.Id != 1 {
+		t.Errorf("after undo waiting on %v in state %v, want team 1 to pick", controller.auction.offeringTeam.Id, controller.state)
+	}
+	if store := controller.store.(*fakeStore); len(store.auctions) != 0 {
+		t.Errorf("store has %v auctions after undo, want 0", len(store.auctions))
+	}
+}
+
+func TestUndoAfterSkippedNomination(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	controller.finishAuction()
+	// Team 2's nomination is skipped, so team 1 offers the next auction
+	// too.
+	controller.nominationTimedOut()
+	if controller.auction.offeringTeam.Id != 1 {
+		t.Fatalf("after skipping team 2 waiting on team %v, want team 1", controller.auction.offeringTeam.Id)
+	}
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 3, Bid: 100})
+	controller.finishAuction()
+
+	sendTestMessage(controller, "one@example.com", UndoAuction{})
+	if controller.state != WAITING_FOR_PICK || controller.auction.offeringTeam.Id != 1 {
+		t.Errorf("after undo waiting on team %v, want team 1 which offered the undone auction", controller.auction.offeringTeam.Id)
+	}
+	restarted, err := NewController(5, controller.store)
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+	if restarted.auction.offeringTeam.Id != 1 {
+		t.Errorf("after restarting waiting on team %v, want team 1", restarted.auction.offeringTeam.Id)
+	}
+}
+
+func TestUndoCompletingAssignment(t *testing.T) {
+	controller := newTestController(t)
+	controller.RequiredPos = map[string]int{"P": 1}
+	if err := controller.prepare(5, controller.store); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	controller.journal("", teamsReadyEvent, nil)
+	controller.startAuction(controller.auction)
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	controller.finishAuction()
+	sendTestMessage(controller, "one@example.com", AssignPlayer{PlayerId: 3, Team: 2, Salary: 100})
+	if controller.state != DRAFT_COMPLETE {
+		t.Fatalf("state after filling the last roster = %v, want DRAFT_COMPLETE", controller.state)
+	}
+
+	sendTestMessage(controller, "one@example.com", UndoAuction{})
+	if controller.state != WAITING_FOR_PICK || controller.auction.offeringTeam.Id != 2 {
+		t.Errorf("after undoing the assignment state = %v, want team 2 to pick", controller.state)
+	}
+}
+
+func TestAuctionTiming(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "two@example.com", SetAuctionTiming{AuctionSeconds: 60, BidExtensionSeconds: 10, ExtensionThresholdSeconds: 5})
+	if controller.Settings.AuctionSeconds != 30 {
+		t.Errorf("non-leader changed the auction timing")
+	}
+	for _, timing := range []SetAuctionTiming{
+		{AuctionSeconds: 0, BidExtensionSeconds: 10, ExtensionThresholdSeconds: 5},
+		{AuctionSeconds: 60, BidExtensionSeconds: -1, ExtensionThresholdSeconds: 5},
+		{AuctionSeconds: 60, BidExtensionSeconds: 10, ExtensionThresholdSeconds: -1},
+	} {
+		sendTestMessage(controller, "one@example.com", timing)
+		if controller.Settings.AuctionSeconds != 30 || controller.Settings.BidExtensionSeconds != 20 || controller.Settings.ExtensionThresholdSeconds != 20 {
+			t.Errorf("timing %+v accepted", timing)
+		}
+	}
+	sendTestMessage(controller, "one@example.com", SetAuctionTiming{AuctionSeconds: 60, BidExtensionSeconds: 10, ExtensionThresholdSeconds: 5})
+	if settings := controller.Settings; settings.AuctionSeconds != 60 || settings.BidExtensionSeconds != 10 || settings.ExtensionThresholdSeconds != 5 {
+		t.Fatalf("settings after changing the timing = %+v", settings)
+	}
+
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	if left := controller.auction.endTime.Sub(time.Now()); left < 59*time.Second || left > 60*time.Second {
+		t.Errorf("new auction has %v left, want 60s", left)
+	}
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: 2, Bid: 150})
+	if left := controller.auction.endTime.Sub(time.Now()); left < 59*time.Second {
+		t.Errorf("early bid shortened the auction to %v", left)
+	}
+	controller.auction.endTime = time.Now().Add(2 * time.Second)
+	sendTestMessage(controller, "one@example.com", Bid{PlayerId: 2, Bid: 200})
+	if left := controller.auction.endTime.Sub(time.Now()); left < 9*time.Second || left > 10*time.Second {
+		t.Errorf("late bid left %v in the auction, want the 10s extension", left)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "two@example.com", PauseDraft{Reason: "lunch"})
+	if controller.state != WAITING_FOR_PICK {
+		t.Fatalf("non-leader paused the draft")
+	}
+	sendTestMessage(controller, "one@example.com", PauseDraft{Reason: "lunch"})
+	if controller.state != DRAFT_PAUSED || controller.pauseReason != "lunch" {
+		t.Fatalf("state after pause = %v, want DRAFT_PAUSED", controller.state)
+	}
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	if controller.state != DRAFT_PAUSED {
+		t.Errorf("pick accepted while paused")
+	}
+	sendTestMessage(controller, "one@example.com", ResumeDraft{})
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	if controller.state != AUCTION_IN_PROGRESS {
+		t.Fatalf("state after resuming and picking = %v, want AUCTION_IN_PROGRESS", controller.state)
+	}
+
+	sendTestMessage(controller, "one@example.com", PauseDraft{})
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: 2, Bid: 150})
+	if controller.auction.highBidder.Id != 1 || controller.auction.bid != 100 {
+		t.Errorf("bid accepted while paused")
+	}
+	controller.auction.remaining = 5 * time.Second
+	sendTestMessage(controller, "one@example.com", ResumeDraft{})
+	if left := controller.auction.endTime.Sub(time.Now()); controller.state != AUCTION_IN_PROGRESS || left < 4*time.Second || left > 5*time.Second {
+		t.Errorf("resumed to %v with %v left, want the auction with 5s left", controller.state, left)
+	}
+}
+
+func TestBidIncrement(t *testing.T) {
+	settings := DraftSettings{
+		BidIncrements: []BidIncrement{
+			{From: 0, Increment: 50},
+			{From: 1000, Increment: 100},
+		},
+	}
+	for _, tc := range []struct{ bid, want int }{{50, 50}, {999, 50}, {1000, 100}, {2500, 100}} {
+		if got := settings.bidIncrement(tc.bid); got != tc.want {
+			t.Errorf("bidIncrement(%v) = %v, want %v", tc.bid, got, tc.want)
+		}
+	}
+
+	controller := newStartedTestController(t)
+	player := testPlayers[2]
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: player.Id, Bid: 100})
+	if controller.auction.highBidder.Id != 1 {
+		t.Errorf("matching bid took the lead from team 1")
+	}
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: player.Id, Bid: 150})
+	if controller.auction.highBidder.Id != 2 {
+		t.Errorf("raise by the minimum increment was rejected")
+	}
+}
+
+func TestNominationTimeout(t *testing.T) {
+	controller := newStartedTestController(t)
+	controller.Settings.NominationTimeout = AutoNominate
+	catcher := &Player{Id: 3, Positions: []string{"C", "U"}}
+	pitcher := &Player{Id: 4, Positions: []string{"P"}}
+	controller.rankedPool = []*Player{catcher, pitcher}
+	controller.teamById(1).Players = append(controller.teamById(1).Players, &OwnedPlayer{
+		Player: &Player{Id: 5, Positions: []string{"U"}},
+	})
+
+	controller.nominationTimedOut()
+	if controller.state != AUCTION_IN_PROGRESS || controller.auction.player != pitcher {
+		t.Fatalf("auto-nominated %v, want the pitcher", controller.auction.player)
+	}
+	if controller.auction.bid != controller.MinSalary {
+		t.Errorf("auto-nominated at %v, want %v", controller.auction.bid, controller.MinSalary)
+	}
+
+	controller.finishAuction()
+	controller.Settings.NominationTimeout = SkipNomination
+	controller.nominationTimedOut()
+	if controller.state != WAITING_FOR_PICK || controller.auction.offeringTeam.Id != 1 {
+		t.Errorf("after skipping team 2 waiting on team %v, want 1", controller.auction.offeringTeam.Id)
+	}
+}
+
+func TestProxyBids(t *testing.T) {
+	controller := newStartedTestController(t)
+	player := testPlayers[2]
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	sendTestMessage(controller, "two@example.com", ProxyBid{PlayerId: player.Id, Max: 300})
+	if controller.auction.highBidder.Id != 2 || controller.auction.bid != 150 {
+		t.Fatalf("after proxy team %v leads at %v, want team 2 at 150", controller.auction.highBidder.Id, controller.auction.bid)
+	}
+	sendTestMessage(controller, "one@example.com", ProxyBid{PlayerId: player.Id, Max: 400})
+	if controller.auction.highBidder.Id != 1 || controller.auction.bid != 350 {
+		t.Errorf("after competing proxies team %v leads at %v, want team 1 at 350", controller.auction.highBidder.Id, controller.auction.bid)
+	}
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: player.Id, Bid: 400})
+	if controller.auction.highBidder.Id != 2 || controller.auction.bid != 400 {
+		t.Errorf("after exhausting proxy team %v leads at %v, want team 2 at 400", controller.auction.highBidder.Id, controller.auction.bid)
+	}
+}
+
+func TestProxyBidTies(t *testing.T) {
+	for _, max := range []int{300, 350} {
+		// Team 2 outbids team 1's pick before team 1 sets an equal proxy.
+		controller := newStartedTestController(t)
+		sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+		sendTestMessage(controller, "two@example.com", ProxyBid{PlayerId: 2, Max: max})
+		sent := controller.seq
+		sendTestMessage(controller, "one@example.com", ProxyBid{PlayerId: 2, Max: max})
+		if controller.auction.highBidder.Id != 2 || controller.auction.bid != max {
+			t.Errorf("tied at %v, team %v leads at %v; want team 2, whose proxy was first, at %v", max, controller.auction.highBidder.Id, controller.auction.bid, max)
+		}
+		if broadcasts := controller.seq - sent; broadcasts > 2 {
+			t.Errorf("tied at %v, resolving proxies sent %v messages, want a single bid", max, broadcasts)
+		}
+
+		// Team 1 leads with a proxy before team 2 sets an equal one.
+		controller = newStartedTestController(t)
+		sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+		sendTestMessage(controller, "one@example.com", ProxyBid{PlayerId: 2, Max: max})
+		sendTestMessage(controller, "two@example.com", ProxyBid{PlayerId: 2, Max: max})
+		if controller.auction.highBidder.Id != 1 || controller.auction.bid != max {
+			t.Errorf("tied at %v, team %v leads at %v; want team 1, whose proxy was first, at %v", max, controller.auction.highBidder.Id, controller.auction.bid, max)
+		}
+	}
+}
+
+func TestNominationQueue(t *testing.T) {
+	controller := newStartedTestController(t)
+	first := testPlayers[3]
+	second := testPlayers[4]
+	sendTestMessage(controller, "two@example.com", SetNominationQueue{
+		Entries: []*Pick{{PlayerId: first.Id, Bid: 100}, {PlayerId: second.Id, Bid: 200}},
+	})
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: first.Id, Bid: 100})
+	controller.finishAuction()
+	if queue := controller.teamById(2).queue; len(queue) != 1 || queue[0].Player.Id != second.Id {
+		t.Fatalf("queue after first player sold = %v, want only the second player", queue)
+	}
+	controller.nominationTimedOut()
+	if controller.state != AUCTION_IN_PROGRESS || controller.auction.player.Id != second.Id || controller.auction.bid != 200 {
+		t.Errorf("timeout nominated %v for %v, want the queued player for 200", controller.auction.player, controller.auction.bid)
+	}
+}
+
+func TestRecoverAutoNomination(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "two@example.com", SetNominationQueue{
+		Entries: []*Pick{{PlayerId: 3, Bid: 150}},
+	})
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	controller.finishAuction()
+	// Team 2 isn't connected, so its queue nominates for it.
+	if controller.state != AUCTION_IN_PROGRESS || controller.auction.player.Id != 3 {
+		t.Fatalf("disconnected team 2 didn't nominate from its queue")
+	}
+	sendTestMessage(controller, "two@example.com", SetNominationQueue{
+		Entries: []*Pick{{PlayerId: 4, Bid: 300}},
+	})
+	sendTestMessage(controller, "one@example.com", Bid{PlayerId: 3, Bid: 200})
+
+	restarted, err := NewController(5, controller.store)
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+	if restarted.state != AUCTION_IN_PROGRESS || restarted.auction.player.Id != 3 || restarted.auction.bid != 200 || restarted.auction.highBidder.Id != 1 {
+		t.Errorf("recovered state %v, want team 1 bidding 200 on player 3", restarted.state)
+	}
+
+	// The nomination clock runs out on team 1 and its queue nominates.
+	controller = newStartedTestController(t)
+	sendTestMessage(controller, "one@example.com", SetNominationQueue{
+		Entries: []*Pick{{PlayerId: 3, Bid: 150}},
+	})
+	controller.nominationTimedOut()
+	sendTestMessage(controller, "one@example.com", SetNominationQueue{
+		Entries: []*Pick{{PlayerId: 4, Bid: 300}},
+	})
+	restarted, err = NewController(5, controller.store)
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+	if restarted.state != AUCTION_IN_PROGRESS || restarted.auction.player.Id != 3 || restarted.auction.bid != 150 {
+		t.Errorf("recovered state %v after the nomination clock ran out, want player 3 nominated for 150", restarted.state)
+	}
+}
+
+func TestResend(t *testing.T) {
+	controller := newStartedTestController(t)
+	conn := Connection{User: &googleauth.Profile{Email: "two@example.com"}}
+	team := controller.teamById(2)
+	last := controller.seq
+	controller.broadcast(SocketMessageFrom(DraftResumed{}))
+	controller.sendToTeam(controller.teamById(1), SocketMessageFrom(NominationQueue{}))
+	controller.sendToTeam(team, SocketMessageFrom(NominationQueue{}))
+
+	sendCh := make(chan *SocketMessage, 10)
+	if !controller.resend(conn, team, sendCh, &Resume{Epoch: controller.epoch, Seq: last}) {
+		t.Fatalf("resend refused a recent resume point")
+	}
+	if len(sendCh) != 2 {
+		t.Errorf("resent %v messages, want 2", len(sendCh))
+	}
+	if controller.resend(conn, team, sendCh, &Resume{Epoch: controller.epoch + 1, Seq: last}) {
+		t.Errorf("resend accepted a resume point from another controller")
+	}
+	controller.history = controller.history[len(controller.history)-2:]
+	if controller.resend(conn, team, sendCh, &Resume{Epoch: controller.epoch, Seq: last}) {
+		t.Errorf("resend accepted a resume point older than its history")
+	}
+}
+
+func TestSpectators(t *testing.T) {
+	controller := newStartedTestController(t)
+	if controller.canSpectate("fan@example.com") {
+		t.Errorf("spectator allowed when spectating is off")
+	}
+	sendTestMessage(controller, "one@example.com", SetSpectators{Allowed: true, Emails: []string{"fan@example.com"}})
+	if !controller.canSpectate("fan@example.com") || controller.canSpectate("other@example.com") {
+		t.Errorf("spectator list not honored")
+	}
+	player := testPlayers[2]
+	sendTestMessage(controller, "fan@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	if controller.state != WAITING_FOR_PICK {
+		t.Errorf("spectator's pick was accepted")
+	}
+}
+
+func TestAssignPlayer(t *testing.T) {
+	controller := newStartedTestController(t)
+	controller.leaders = append(controller.leaders, "boss@example.com")
+	player := testPlayers[2]
+	sendTestMessage(controller, "boss@example.com", AssignPlayer{PlayerId: player.Id, Team: 2, Salary: 100})
+	if len(controller.CompletedAuctions) != 1 || controller.CompletedAuctions[0].OfferingTeam != 0 {
+		t.Fatalf("player not assigned: %v", controller.CompletedAuctions)
+	}
+	if players := controller.teamById(2).Players; len(players) != 1 || players[0].Salary != 100 {
+		t.Errorf("team 2 has %v, want the assigned player", players)
+	}
+	if controller.state != WAITING_FOR_PICK || controller.auction.offeringTeam.Id != 1 {
+		t.Errorf("assignment changed whose turn it is")
+	}
+	sendTestMessage(controller, "boss@example.com", AssignPlayer{PlayerId: player.Id, Team: 1, Salary: 100})
+	if len(controller.CompletedAuctions) != 1 {
+		t.Errorf("owned player assigned twice")
+	}
+	sendTestMessage(controller, "boss@example.com", Pick{PlayerId: 3, Bid: 100})
+	if controller.state != WAITING_FOR_PICK {
+		t.Errorf("pick accepted from a leader without a team")
+	}
+}
+
+func TestKeepers(t *testing.T) {
+	controller := newTestController(t)
+	player := testPlayers[2]
+	sendTestMessage(controller, "one@example.com", SetKeeper{Team: 2, PlayerId: player.Id, Salary: 100})
+	if players := controller.teamById(2).Players; len(players) != 1 || !players[0].Keeper {
+		t.Fatalf("team 2 has %v, want the keeper", players)
+	}
+	sendTestMessage(controller, "one@example.com", SetKeeper{Team: 1, PlayerId: player.Id, Salary: 100})
+	if len(controller.teamById(1).Players) != 0 {
+		t.Errorf("player kept by two teams")
+	}
+	sendTestMessage(controller, "one@example.com", SetKeeper{Team: 1, PlayerId: 3, Salary: 1000})
+	if len(controller.teamById(1).Players) != 0 {
+		t.Errorf("keeper accepted over the salary cap")
+	}
+	sendTestMessage(controller, "two@example.com", RemoveKeeper{Team: 2, PlayerId: 2})
+	if len(controller.teamById(2).Players) != 1 {
+		t.Errorf("keeper removed by a non-leader")
+	}
+	controller.startAuction(controller.auction)
+	sendTestMessage(controller, "one@example.com", RemoveKeeper{Team: 2, PlayerId: 2})
+	if len(controller.teamById(2).Players) != 1 {
+		t.Errorf("keeper removed after the draft started")
+	}
+}
+
+func TestKeepersFillRosters(t *testing.T) {
+	controller := newTestController(t)
+	controller.RequiredPos = map[string]int{"P": 1}
+	if err := controller.prepare(5, controller.store); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	sendTestMessage(controller, "one@example.com", SetKeeper{Team: 1, PlayerId: 2, Salary: 100})
+	sendTestMessage(controller, "one@example.com", SetKeeper{Team: 2, PlayerId: 3, Salary: 100})
+	if controller.state != DRAFT_COMPLETE || controller.auction != nil {
+		t.Fatalf("state with every roster kept full = %v, want DRAFT_COMPLETE", controller.state)
+	}
+	sendTestMessage(controller, "one@example.com", RemoveKeeper{Team: 2, PlayerId: 3})
+	if controller.state != WAITING_FOR_TEAMS || controller.auction == nil || controller.auction.offeringTeam.Id != 2 {
+		t.Errorf("state after removing a keeper = %v, want team 2 to nominate first", controller.state)
+	}
+}
+
+func TestNominateOwnedPlayer(t *testing.T) {
+	controller := newStartedTestController(t)
+	player := testPlayers[2]
+	sendTestMessage(controller, "one@example.com", AssignPlayer{PlayerId: player.Id, Team: 2, Salary: 100})
+	if controller.ownerOf(2) != controller.teamById(2) {
+		t.Fatalf("assigned player not indexed")
+	}
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: player.Id, Bid: 100})
+	if controller.state != WAITING_FOR_PICK {
+		t.Errorf("owned player nominated")
+	}
+	sendTestMessage(controller, "one@example.com", UndoAuction{})
+	if controller.isOwned(2) {
+		t.Errorf("undone player still owned")
+	}
+}
+
+func TestPlayerCatalog(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 99, Bid: 100})
+	if controller.state != WAITING_FOR_PICK {
+		t.Errorf("unknown player nominated")
+	}
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	if controller.state != AUCTION_IN_PROGRESS || controller.auction.player != testPlayers[2] {
+		t.Errorf("nominated %v, want the catalog's player", controller.auction.player)
+	}
+}
+
+func TestMaxTeamCanBid(t *testing.T) {
+	controller := newStartedTestController(t)
+	team := controller.teamById(1)
+	if got := controller.maxTeamCanBid(team); got != 900 {
+		t.Errorf("max bid with an empty roster = %v, want 900", got)
+	}
+	controller.MinSalary = 100
+	controller.MaxSalary = 500
+	if got := controller.maxTeamCanBid(team); got != 500 {
+		t.Errorf("max bid limited by the max salary = %v, want 500", got)
+	}
+	for i := int64(2); i <= 4; i++ {
+		team.Players = append(team.Players, &OwnedPlayer{Player: testPlayers[i], Salary: 100})
+	}
+	if got := controller.maxTeamCanBid(team); got != 0 {
+		t.Errorf("max bid with a full roster = %v, want 0", got)
+	}
+	if got := formatMoney(1250); got != "$12.50" {
+		t.Errorf("formatMoney(1250) = %v, want $12.50", got)
+	}
+}
+
+func TestRules(t *testing.T) {
+	controller := newStartedTestController(t)
+	controller.RuleConfigs = []*RuleConfig{{Kind: MaxPitchersRule, Limit: 1}}
+	if err := controller.prepare(5, controller.store); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	team := controller.teamById(1)
+	action := &Action{Kind: NominateAction, Team: team, Player: testPlayers[2], Amount: 25}
+	if rejection := controller.checkRules(action); rejection == nil || rejection.Rule != "increment" {
+		t.Errorf("opening bid under the minimum salary rejected by %v, want increment", rejection)
+	}
+	action.Amount = 1000
+	if rejection := controller.checkRules(action); rejection == nil || rejection.Rule != "budget" {
+		t.Errorf("nomination above the team's max bid rejected by %v, want budget", rejection)
+	}
+	action.Amount = 100
+	if rejection := controller.checkRules(action); rejection != nil {
+		t.Errorf("valid nomination rejected: %v", rejection)
+	}
+	controller.rules = append(controller.rules, openingBidCapRule{max: 75})
+	if rejection := controller.checkRules(action); rejection == nil || rejection.Rule != OpeningBidCapRule {
+		t.Errorf("opening bid above the cap rejected by %v, want %v", rejection, OpeningBidCapRule)
+	}
+	controller.rules = controller.rules[:len(controller.rules)-1]
+	team.Players = append(team.Players, &OwnedPlayer{Player: testPlayers[3], Salary: 100})
+	if rejection := controller.checkRules(action); rejection == nil || rejection.Rule != MaxPitchersRule {
+		t.Errorf("second pitcher rejected by %v, want %v", rejection, MaxPitchersRule)
+	}
+	controller.RuleConfigs = []*RuleConfig{{Kind: "no_such_rule"}}
+	if err := controller.prepare(5, controller.store); err == nil {
+		t.Errorf("prepare accepted an unknown rule")
+	}
+}
+
+func TestMaxPitchersPositions(t *testing.T) {
+	controller := newTestController(t)
+	starter := &Player{Id: 10, BasePositions: []string{"SP"}, Positions: []string{"SP"}}
+	reliever := &Player{Id: 11, BasePositions: []string{"RP"}, Positions: []string{"RP"}}
+	team := &Team{Players: []*OwnedPlayer{{Player: starter}}}
+	action := &Action{Kind: BidAction, Team: team, Player: reliever}
+
+	rule, err := newRule(&RuleConfig{Kind: MaxPitchersRule, Limit: 1, Positions: "SP|RP"})
+	if err != nil {
+		t.Fatalf("newRule: %v", err)
+	}
+	if rejection := rule.Check(controller, action); rejection == nil {
+		t.Errorf("second SP|RP pitcher allowed")
+	}
+	rule, err = newRule(&RuleConfig{Kind: MaxPitchersRule, Limit: 1})
+	if err != nil {
+		t.Fatalf("newRule: %v", err)
+	}
+	if rejection := rule.Check(controller, action); rejection != nil {
+		t.Errorf("players without a P slot counted as pitchers: %v", rejection)
+	}
+}
+
+func TestAssignSlots(t *testing.T) {
+	slots := rosterSlots(map[string]int{"SS": 1, "2B": 1, "U": 1})
+	utility := &Player{Id: 1, Positions: []string{"2B", "SS", "U"}}
+	shortstop := &Player{Id: 2, Positions: []string{"SS"}}
+	second := &Player{Id: 3, Positions: []string{"2B"}}
+	slotOf, ok := assignSlots([]*Player{utility, shortstop, second}, slots)
+	if !ok {
+		t.Fatalf("assignSlots couldn't fit players that fit")
+	}
+	if got := slots[slotOf[0]]; got != "U" {
+		t.Errorf("multi-position player slotted at %v, want U", got)
+	}
+	if _, ok := assignSlots([]*Player{shortstop, shortstop}, slots); ok {
+		t.Errorf("assignSlots fit two shortstops in one SS slot")
+	}
+
+	controller := newStartedTestController(t)
+	team := controller.teamById(1)
+	controller.addPlayer(team, &OwnedPlayer{Player: testPlayers[2], Salary: 100})
+	if team.Players[0].Slot != "P" || len(team.OpenSlots) != 2 {
+		t.Errorf("after adding a pitcher slot = %v, open = %v", team.Players[0].Slot, team.OpenSlots)
+	}
+}
+
+func TestSlotRules(t *testing.T) {
+	rules := SlotRules{"MI": "2B|SS", "U": "!P", "BN": "*"}
+	for _, tc := range []struct {
+		base []string
+		want []string
+	}{
+		{[]string{"SS"}, []string{"SS", "BN", "MI", "U"}},
+		{[]string{"P"}, []string{"P", "BN"}},
+		{[]string{"SP", "DH"}, []string{"SP", "DH", "BN", "U"}},
+	} {
+		if got := rules.Positions(tc.base); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("Positions(%v) = %v, want %v", tc.base, got, tc.want)
+		}
+	}
+}
+
+func TestSearchPlayers(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "one@example.com", AssignPlayer{PlayerId: 2, Team: 1, Salary: 100})
+	sendTestMessage(controller, "one@example.com", AssignPlayer{PlayerId: 3, Team: 1, Salary: 100})
+	supervisor := NewSupervisor(controller.store)
+
+	results, err := supervisor.SearchPlayers(5, &PlayerSearch{Email: "two@example.com", Limit: 1})
+	if err != nil {
+		t.Fatalf("SearchPlayers: %v", err)
+	}
+	if len(results.Players) != 1 || results.Players[0].Id != 2 || results.Players[0].Owner != 1 || results.Next != "2" {
+		t.Fatalf("first page = %v next %q, want player 2 owned by team 1 and next 2", results.Players, results.Next)
+	}
+	after, err := ParseCursor(results.Next)
+	if err != nil {
+		t.Fatalf("ParseCursor: %v", err)
+	}
+	results, err = supervisor.SearchPlayers(5, &PlayerSearch{PlayerQuery: PlayerQuery{After: after}, Email: "two@example.com", Limit: 1})
+	if err != nil || len(results.Players) != 1 || results.Players[0].Id != 3 {
+		t.Errorf("second page = %v, %v; want player 3", results, err)
+	}
+
+	results, err = supervisor.SearchPlayers(5, &PlayerSearch{Available: true, Email: "two@example.com"})
+	if err != nil || len(results.Players) != 1 || results.Players[0].Id != 4 || results.Next != "" {
+		t.Errorf("available players = %v, %v; want only player 4", results, err)
+	}
+	results, err = supervisor.SearchPlayers(5, &PlayerSearch{Fits: true, Email: "one@example.com"})
+	if err != nil || len(results.Players) != 0 {
+		t.Errorf("players that fit a roster without pitching slots = %v, %v; want none", results, err)
+	}
+	if _, err := supervisor.SearchPlayers(5, &PlayerSearch{Email: "nobody@example.com"}); err != ErrNotAllowed {
+		t.Errorf("search by someone who may not view the draft: err = %v, want ErrNotAllowed", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "one@example.com", AssignPlayer{PlayerId: 2, Team: 1, Salary: 300})
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 3, Bid: 100})
+	snapshot := controller.snapshot()
+	if snapshot.State != "auction_in_progress" || snapshot.Auction == nil || snapshot.Auction.Player.Id != 3 {
+		t.Errorf("snapshot state %v with auction %v, want player 3 on the block", snapshot.State, snapshot.Auction)
+	}
+	if budget := snapshot.Budgets[0]; budget.Spent != 300 || budget.Left != 700 || budget.MaxBid != 650 {
+		t.Errorf("team 1 budget = %+v, want 300 spent, 700 left and a max bid of 650", budget)
+	}
+
+	supervisor := NewSupervisor(controller.store)
+	if _, err := supervisor.Snapshot(5, "stranger@example.com"); err != ErrNotAllowed {
+		t.Errorf("snapshot for a stranger: err = %v, want ErrNotAllowed", err)
+	}
+	encoded, err := supervisor.Snapshot(5, "two@example.com")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	var stored struct {
+		Picks   []*AuctionComplete `json:"picks"`
+		Budgets []*TeamBudget      `json:"budgets"`
+	}
+	if err := json.Unmarshal(encoded, &stored); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(stored.Picks) != 1 || len(stored.Budgets) != 2 || stored.Budgets[0].Spent != 300 {
+		t.Errorf("snapshot from the store has picks %v and budgets %v", stored.Picks, stored.Budgets)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	store := &fakeStore{}
+	supervisor := NewSupervisor(store)
+	view, err := supervisor.DraftConfig(5)
+	if err != nil {
+		t.Fatalf("DraftConfig: %v", err)
+	}
+	config := view.DraftConfig
+	config.Name = "Renamed"
+	config.SalaryCap = 2000
+	if err := supervisor.UpdateDraft(5, &config); err != nil {
+		t.Errorf("UpdateDraft before the draft started: %v", err)
+	}
+	if _, err := supervisor.AddTeam(5, &TeamConfig{Name: "Three"}); err != nil {
+		t.Errorf("AddTeam before the draft started: %v", err)
+	}
+	if err := supervisor.AddOwner(5, 2, "one@example.com"); err == nil {
+		t.Errorf("owner of team 1 added to team 2")
+	}
+
+	store.events = append(store.events, &JournalEvent{Seq: 1, Type: teamsReadyEvent})
+	if err := supervisor.UpdateDraft(5, &config); err != ErrDraftStarted {
+		t.Errorf("UpdateDraft changing the salary cap after the draft started: err = %v, want ErrDraftStarted", err)
+	}
+	config = view.DraftConfig
+	config.Name = "Renamed"
+	if err := supervisor.UpdateDraft(5, &config); err != nil {
+		t.Errorf("UpdateDraft changing the name after the draft started: %v", err)
+	}
+	if err := supervisor.DeleteTeam(5, 2); err != ErrDraftStarted {
+		t.Errorf("DeleteTeam after the draft started: err = %v, want ErrDraftStarted", err)
+	}
+	if err := supervisor.UpdateTeam(5, 2, &TeamConfig{Name: "Two", Order: 1}); err != ErrDraftStarted {
+		t.Errorf("reordering teams after the draft started: err = %v, want ErrDraftStarted", err)
+	}
+	if err := supervisor.AddLeader(5, "boss@example.com"); err != nil {
+		t.Errorf("AddLeader after the draft started: %v", err)
+	}
+}
+
+func TestAdminSettings(t *testing.T) {
+	store := &fakeStore{}
+	supervisor := NewSupervisor(store)
+	if _, err := supervisor.CreateDraft(&DraftConfig{Name: "New", SalaryCap: 1000}); err != nil {
+		t.Fatalf("CreateDraft: %v", err)
+	}
+	if settings := store.created.Settings; settings == nil || settings.AuctionSeconds != 30 || settings.BidExtensionSeconds != 20 || settings.NominationTimeout != SkipNomination {
+		t.Errorf("draft created without settings has settings %+v, want the defaults", settings)
+	}
+
+	invalid := []DraftSettings{
+		{AuctionSeconds: 0, BidExtensionSeconds: 20},
+		{AuctionSeconds: 30, BidExtensionSeconds: -5},
+		{AuctionSeconds: 30, BidExtensionSeconds: 20, ExtensionThresholdSeconds: -1},
+		{AuctionSeconds: 30, BidExtensionSeconds: 20, NominationSeconds: -1},
+		{AuctionSeconds: 30, BidExtensionSeconds: 20, NominationTimeout: "wait"},
+		{AuctionSeconds: 30, BidExtensionSeconds: 20, BidIncrements: []BidIncrement{{From: 0, Increment: 0}}},
+		{AuctionSeconds: 30, BidExtensionSeconds: 20, BidIncrements: []BidIncrement{{From: 500, Increment: 100}, {From: 100, Increment: 50}}},
+	}
+	for _, settings := range invalid {
+		settings := settings
+		config := &DraftConfig{Name: "New", SalaryCap: 1000, Settings: &settings}
+		if _, err := supervisor.CreateDraft(config); err == nil {
+			t.Errorf("CreateDraft accepted settings %+v", settings)
+		}
+		if err := supervisor.UpdateDraft(5, config); err == nil {
+			t.Errorf("UpdateDraft accepted settings %+v", settings)
+		}
+	}
+
+	view, err := supervisor.DraftConfig(5)
+	if err != nil {
+		t.Fatalf("DraftConfig: %v", err)
+	}
+	config := view.DraftConfig
+	config.Name = "Renamed"
+	config.Settings = nil
+	if err := supervisor.UpdateDraft(5, &config); err != nil {
+		t.Errorf("UpdateDraft without settings: %v", err)
+	}
+}
+
+func TestRenameWithDefaultMinSalary(t *testing.T) {
+	store := &fakeStore{}
+	supervisor := NewSupervisor(store)
+	view, err := supervisor.DraftConfig(5)
+	if err != nil {
+		t.Fatalf("DraftConfig: %v", err)
+	}
+	// The store has no minimum salary; running drafts use the default.
+	config := view.DraftConfig
+	config.Name = "Renamed"
+	config.MinSalary = defaultMinSalary
+	store.events = append(store.events, &JournalEvent{Seq: 1, Type: teamsReadyEvent})
+	if err := supervisor.UpdateDraft(5, &config); err != nil {
+		t.Errorf("UpdateDraft with the default minimum salary: %v", err)
+	}
+
+	controller, err := NewController(5, store)
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+	supervisor.drafts[5] = controller
+	go controller.EventLoop()
+	config.MinSalary = 0
+	if err := supervisor.UpdateDraft(5, &config); err != nil {
+		t.Errorf("renaming a running draft without a minimum salary: %v", err)
+	}
+}
+
+func TestConfigureStoppedDraft(t *testing.T) {
+	supervisor := NewSupervisor(&fakeStore{})
+	started := make(chan struct{})
+	err := supervisor.configure(5, func(c *DraftController, running bool) error {
+		// The supervisor isn't locked while the draft is changed.
+		supervisor.Lock()
+		supervisor.Unlock()
+		go func() {
+			supervisor.lockConfigured(5)
+			supervisor.Unlock()
+			close(started)
+		}()
+		select {
+		case <-started:
+			t.Errorf("draft could start while it was being configured")
+		case <-time.After(10 * time.Millisecond):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	<-started
+}
+
+func TestOwnerConnections(t *testing.T) {
+	store := &fakeStore{}
+	controller, err := NewController(5, store)
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+	supervisor := NewSupervisor(store)
+	supervisor.drafts[5] = controller
+	controller.Settings.AllowSpectators = true
+	conn := Connection{User: &googleauth.Profile{Email: "new@example.com"}}
+	closed := func(ch chan *SocketMessage) bool {
+		select {
+		case _, ok := <-ch:
+			return !ok
+		default:
+			return false
+		}
+	}
+
+	spectating := make(chan *SocketMessage, 10)
+	controller.observers[conn] = spectating
+	go controller.EventLoop()
+	if err := supervisor.AddOwner(5, 2, "new@example.com"); err != nil {
+		t.Fatalf("AddOwner: %v", err)
+	}
+	if len(controller.observers) != 0 || !closed(spectating) {
+		t.Errorf("spectator connection left open after becoming an owner")
+	}
+	if controller.owners["new@example.com"] != controller.teamById(2) {
+		t.Errorf("new@example.com doesn't own team 2 after AddOwner")
+	}
+
+	owning := make(chan *SocketMessage, 10)
+	controller.teamById(2).connections[conn] = owning
+	go controller.EventLoop()
+	if err := supervisor.RemoveOwner(5, 2, "new@example.com"); err != nil {
+		t.Fatalf("RemoveOwner: %v", err)
+	}
+	if len(controller.teamById(2).connections) != 0 || !closed(owning) {
+		t.Errorf("team connection left open after RemoveOwner")
+	}
+	if team := controller.removeConnection(conn); team != nil {
+		t.Errorf("closed connection unregistered from team %v", team.Id)
+	}
+}
+
+func TestExport(t *testing.T) {
+	controller := newTestController(t)
+	controller.teamById(1).Name = "One"
+	controller.teamById(2).Name = "Two"
+	sendTestMessage(controller, "one@example.com", SetKeeper{Team: 2, PlayerId: 4, Salary: 200})
+	controller.journal("", teamsReadyEvent, nil)
+	controller.startAuction(controller.auction)
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	sendTestMessage(controller, "two@example.com", Bid{PlayerId: 2, Bid: 150})
+	controller.finishAuction()
+	sendTestMessage(controller, "one@example.com", AssignPlayer{PlayerId: 3, Team: 1, Salary: 100})
+
+	var out bytes.Buffer
+	if err := WriteCSV(&out, controller.exportRows()); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "pick_number,player_id,player,mlbteam,salary_cents,offering_team,winning_team,slot,keeper\n" +
+		",4,Chris Sale,BOS,200,,Two,P,true\n" +
+		"1,2,Clayton Kershaw,LAD,150,One,Two,P,false\n" +
+		"2,3,Max Scherzer,WSH,100,,One,P,false\n"
+	if out.String() != want {
+		t.Errorf("exported\n%v\nwant\n%v", out.String(), want)
+	}
+
+	encoded, err := json.Marshal(controller.exportRows()[0])
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var keeper map[string]interface{}
+	if err := json.Unmarshal(encoded, &keeper); err != nil || keeper["salary_cents"] != 200.0 {
+		t.Errorf("exported JSON %s, want salary_cents 200", encoded)
+	}
+}
+
+func TestJournalAccess(t *testing.T) {
+	controller := newStartedTestController(t)
+	sendTestMessage(controller, "one@example.com", Pick{PlayerId: 2, Bid: 100})
+	sendTestMessage(controller, "two@example.com", ProxyBid{PlayerId: 2, Max: 500})
+	supervisor := NewSupervisor(controller.store)
+
+	events, err := supervisor.Journal(5, "one@example.com")
+	if err != nil || events[len(events)-1].Type != "ProxyBid" {
+		t.Errorf("leader's journal = %v, %v; want the proxy bid", events, err)
+	}
+	events, err = supervisor.Journal(5, "two@example.com")
+	if err != nil {
+		t.Fatalf("Journal: %v", err)
+	}
+	for _, event := range events {
+		if privateEvents[event.Type] {
+			t.Errorf("non-leader's journal has %v event", event.Type)
+		}
+	}
+	if _, err := supervisor.Journal(5, "stranger@example.com"); err != ErrNotAllowed {
+		t.Errorf("journal for a stranger: err = %v, want ErrNotAllowed", err)
+	}
+
+	if _, err := supervisor.Replay(5, "two@example.com", 1); err != ErrNotAllowed {
+		t.Errorf("replay for a non-leader: err = %v, want ErrNotAllowed", err)
+	}
+	encoded, err := supervisor.Replay(5, "one@example.com", events[1].Seq)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	var replayed struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(encoded, &replayed); err != nil || replayed.State != "auction_in_progress" {
+		t.Errorf("replayed after the pick: state = %q, %v; want auction_in_progress", replayed.State, err)
 	}
 }